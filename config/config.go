@@ -13,10 +13,137 @@ type Config struct {
 	// Default is ":4317".
 	Addr string `env:"ADDR, default=:4317"`
 
-	// AttributeKey is the log attribute key to aggregate on.
+	// HTTPAddr is the address for the OTLP/HTTP receiver to listen on.
 	//
-	// This key is required.
-	AttributeKey string `env:"ATTRIBUTE_KEY, required"`
+	// The receiver accepts POST /v1/logs requests with protobuf or JSON bodies.
+	// Set to an empty string to disable the HTTP receiver.
+	//
+	// Default is ":4318".
+	HTTPAddr string `env:"HTTP_ADDR, default=:4318"`
+
+	// AttributeKeys is the comma-separated list of log attribute keys to aggregate on.
+	//
+	// Records are counted per combination of values of all the keys,
+	// e.g. "service.name,http.route,status".
+	//
+	// At least one key is required.
+	AttributeKeys []string `env:"ATTRIBUTE_KEY, required"`
+
+	// BodyRegex is a regular expression run against the log body when an
+	// attribute key is not found in the log, scope or resource attributes.
+	//
+	// The value for a key is taken from the named capture group matching the key,
+	// with every character other than letters, digits and underscores replaced
+	// by an underscore. For example, "service.name" is read from
+	// "(?P<service_name>\w+)".
+	//
+	// Leave empty to disable regex body extraction.
+	BodyRegex string `env:"BODY_REGEX"`
+
+	// BodyJSONPointers maps attribute keys to JSON pointers (RFC 6901) into the log body,
+	// used when a key is found neither in the attributes nor by BodyRegex.
+	//
+	// The pointer is resolved against bodies that parse as JSON objects or arrays,
+	// and against KvlistValue bodies. For example "service.name:/service/name".
+	BodyJSONPointers map[string]string `env:"BODY_JSON_POINTERS"`
+
+	// NormalizeTrim trims leading and trailing white space from the values of the AttributeKeys,
+	// so that "checkout " and "checkout" are counted together.
+	//
+	// Values are normalized in this order: trimmed, lowercased, rewritten by NormalizeReplace,
+	// mapped by NormalizeMappingFile and truncated to NormalizeMaxLength.
+	// Attributes that are not found are left as "unknown".
+	//
+	// Default is false.
+	NormalizeTrim bool `env:"NORMALIZE_TRIM, default=false"`
+
+	// NormalizeLowercase lowercases the values of the AttributeKeys, see NormalizeTrim.
+	//
+	// Default is false.
+	NormalizeLowercase bool `env:"NORMALIZE_LOWERCASE, default=false"`
+
+	// NormalizeReplace is the semicolon-separated list of regex replacements applied to
+	// the values of the AttributeKeys, in order, see NormalizeTrim.
+	//
+	// Each replacement is written "regex=>replacement", and the replacement may refer to
+	// capture groups as in regexp.Regexp.ReplaceAllString, e.g. "-svc$=>;^(\w+)-v\d+$=>$1".
+	NormalizeReplace []string `env:"NORMALIZE_REPLACE, delimiter=;"`
+
+	// NormalizeMappingFile is the path to a JSON file mapping values of the AttributeKeys
+	// to canonical values, per key, applied after NormalizeReplace, see NormalizeTrim.
+	//
+	// For example {"service.name": {"checkout-svc": "checkout", "co": "checkout"}}.
+	// Values without a mapping are kept.
+	//
+	// Leave empty to disable value mapping.
+	NormalizeMappingFile string `env:"NORMALIZE_MAPPING_FILE"`
+
+	// NormalizeMaxLength is the maximum length, in characters, of the values of the
+	// AttributeKeys. Longer values are truncated, see NormalizeTrim.
+	//
+	// Default is 0, which means no limit.
+	NormalizeMaxLength int `env:"NORMALIZE_MAX_LENGTH, default=0"`
+
+	// ValueAttributeKey is the attribute key of a numeric value to aggregate per group,
+	// e.g. "http.response.size" or "duration_ms".
+	//
+	// The count, sum, min, max and mean of the values are reported per group.
+	// The key is looked up in the log, scope and resource attributes, and its value
+	// must be an int, a double, or a string holding a number. Records without a
+	// numeric value are still counted.
+	//
+	// Leave empty to only count records.
+	ValueAttributeKey string `env:"VALUE_ATTRIBUTE_KEY"`
+
+	// DistributionAttributeKey is the attribute key of a numeric value, such as
+	// a duration, whose distribution is tracked per group, e.g. "duration_ms".
+	//
+	// Values are counted into a base-2 exponential histogram per group, following
+	// the OTLP ExponentialHistogram model, from which DistributionQuantiles are estimated.
+	// The key is looked up like ValueAttributeKey.
+	//
+	// Leave empty to disable distributions.
+	DistributionAttributeKey string `env:"DISTRIBUTION_ATTRIBUTE_KEY"`
+
+	// DistributionQuantiles is the comma-separated list of quantiles, between 0 and 1,
+	// reported for each group's distribution.
+	//
+	// Default is "0.5,0.95,0.99".
+	DistributionQuantiles []float64 `env:"DISTRIBUTION_QUANTILES, default=0.5,0.95,0.99"`
+
+	// DistributionMaxBuckets is the maximum number of buckets per sign of a distribution histogram.
+	//
+	// The histogram resolution is halved whenever the values no longer fit, so more
+	// buckets give more accurate quantiles at the cost of memory. With 160 buckets,
+	// quantiles of values spanning 1ms to 1h are within 10%.
+	//
+	// Default is 160.
+	DistributionMaxBuckets int `env:"DISTRIBUTION_MAX_BUCKETS, default=160"`
+
+	// DistinctAttributeKey is the attribute key whose distinct values are counted
+	// per group, e.g. "user.id" to count the unique users per service.
+	//
+	// Distinct values are estimated with a HyperLogLog sketch per group rather
+	// than stored. The key is looked up like ValueAttributeKey, and its value
+	// may be of any type. Records without the attribute are still counted.
+	//
+	// Leave empty to disable distinct counts.
+	DistinctAttributeKey string `env:"DISTINCT_ATTRIBUTE_KEY"`
+
+	// DistinctPrecision is the precision of the distinct count sketches, between 4 and 18.
+	//
+	// Each sketch uses 2^precision bytes for a standard error of about
+	// 1.04/sqrt(2^precision), e.g. 16KB and 0.8% with a precision of 14.
+	//
+	// Default is 14.
+	DistinctPrecision int `env:"DISTINCT_PRECISION, default=14"`
+
+	// SeverityBreakdown enables breaking each group's count down by severity range,
+	// TRACE, DEBUG, INFO, WARN, ERROR and FATAL, as defined by the OTLP SeverityNumber.
+	// Records without a severity number are counted as UNSPECIFIED.
+	//
+	// Default is false.
+	SeverityBreakdown bool `env:"SEVERITY_BREAKDOWN, default=false"`
 
 	// AggregationWindow is the time window for aggregation.
 	//
@@ -25,11 +152,206 @@ type Config struct {
 	// Default is 10s.
 	AggregationWindow time.Duration `env:"AGGREGATION_WINDOW, default=10s"`
 
+	// WindowSlide is the interval at which overlapping aggregation windows of
+	// AggregationWindow are emitted, e.g. a 5m window every 30s.
+	//
+	// Records are aggregated into panes of WindowSlide, and each emitted window
+	// merges the panes it covers, so memory is bounded by AggregationWindow / WindowSlide panes.
+	// The cardinality limits apply per pane. AggregationWindow must be a multiple of WindowSlide.
+	// Sliding windows cannot be exported with the otlp exporter, whose delta metrics
+	// would count each record once per overlapping window.
+	//
+	// Default is 0, which means tumbling windows of AggregationWindow.
+	WindowSlide time.Duration `env:"WINDOW_SLIDE, default=0"`
+
+	// AlignWindows aligns processing-time window boundaries to multiples of the
+	// window duration (or WindowSlide) since the Unix epoch, so that windows of
+	// different replicas line up and can be merged downstream.
+	//
+	// When disabled, windows are timed from the process start.
+	// Event-time windows are always aligned.
+	//
+	// Default is false.
+	AlignWindows bool `env:"ALIGN_WINDOWS, default=false"`
+
+	// WindowTime is the notion of time records are assigned to aggregation windows by.
+	//
+	// Supported values are:
+	//   - "processing": a window holds the records received since the previous window was flushed
+	//   - "event": tumbling windows of AggregationWindow keyed off the log record's timestamp,
+	//     falling back to its observed timestamp. A window is flushed once the watermark,
+	//     the latest timestamp seen minus AllowedLateness, passes its end.
+	//
+	// Event-time windows only close as newer records arrive; the remaining windows are flushed on shutdown.
+	//
+	// Default is "processing".
+	WindowTime string `env:"WINDOW_TIME, default=processing"`
+
+	// AllowedLateness is how far behind the latest timestamp seen records can arrive
+	// and still be aggregated into their event-time window. Timestamps further ahead
+	// of the wall clock than AllowedLateness do not advance the watermark past it.
+	//
+	// Default is 0.
+	AllowedLateness time.Duration `env:"ALLOWED_LATENESS, default=0"`
+
+	// LateDataPolicy is how records arriving after their event-time window was flushed are handled.
+	//
+	// Supported values are:
+	//   - "drop": late records are discarded
+	//   - "count": late records are discarded, but their number is reported with the next flushed window
+	//   - "correct": late records are aggregated into a correction of their window, emitted on the next flush
+	//
+	// Default is "drop".
+	LateDataPolicy string `env:"LATE_DATA_POLICY, default=drop"`
+
 	// MaxReceiveMessageSize is the maximum gRPC receive message size in bytes.
 	//
+	// It also limits the (decompressed) body size accepted by the HTTP receiver.
+	//
 	// Default is 4MB.
 	MaxReceiveMessageSize int `env:"MAX_RECEIVE_MESSAGE_SIZE, default=4194304"`
 
+	// TLSCertFile is the PEM-encoded certificate (chain) file the gRPC and HTTP receivers
+	// serve TLS with. It requires TLSKeyFile.
+	//
+	// Leave empty to serve plaintext.
+	TLSCertFile string `env:"TLS_CERT_FILE"`
+
+	// TLSKeyFile is the PEM-encoded private key file of TLSCertFile.
+	TLSKeyFile string `env:"TLS_KEY_FILE"`
+
+	// TLSClientCAFile is a PEM-encoded file of the certificate authorities client
+	// certificates are verified against. When set, clients must present a valid
+	// certificate (mutual TLS). It requires TLSCertFile.
+	//
+	// Leave empty to not request client certificates.
+	TLSClientCAFile string `env:"TLS_CLIENT_CA_FILE"`
+
+	// TLSMinVersion is the minimum TLS version accepted by the receivers, "1.2" or "1.3".
+	//
+	// Default is "1.2".
+	TLSMinVersion string `env:"TLS_MIN_VERSION, default=1.2"`
+
+	// TLSReloadInterval is how often the TLS certificate, key and client CA files are
+	// checked for changes, so rotated certificates are picked up without a restart.
+	// Files are checked on new connections, at most once per interval.
+	//
+	// Default is 10s. 0 disables reloading.
+	TLSReloadInterval time.Duration `env:"TLS_RELOAD_INTERVAL, default=10s"`
+
+	// AuthTokens maps principals to the static tokens authenticating them, e.g.
+	// "checkout:s3cr3t,payments:t0k3n". When AuthTokens or AuthKeysFile is set, export
+	// requests must carry a known token in an "authorization: Bearer <token>" or
+	// "x-api-key: <token>" header, and are rejected as unauthenticated otherwise.
+	//
+	// Leave AuthTokens and AuthKeysFile empty to accept unauthenticated requests.
+	AuthTokens map[string]string `env:"AUTH_TOKENS"`
+
+	// AuthKeysFile is the path to a file of hashed API keys, see AuthTokens.
+	//
+	// Each line holds a principal and the hex-encoded SHA-256 hash of its key, separated
+	// by white space, e.g. "checkout 2bb80d53...". Empty lines and lines starting with
+	// "#" are ignored.
+	AuthKeysFile string `env:"AUTH_KEYS_FILE"`
+
+	// Processors is the comma-separated list of processors log records go through,
+	// in order, before being deduplicated and aggregated.
+	//
+	// Supported processors are:
+	//   - "filter": drops records according to FilterInclude and FilterExclude.
+	//   - "sample": samples records according to SamplingRatio and SamplingRates.
+	//   - "redact": masks or hashes sensitive values according to RedactPatterns and RedactRegexes.
+	//
+	// Default is "filter,sample".
+	Processors []string `env:"PROCESSORS, default=filter,sample"`
+
+	// FilterInclude is the semicolon-separated list of rules a log record must all
+	// match to be processed. Records failing any rule are dropped before deduplication.
+	//
+	// Each rule is a field, optionally followed by an operator and a value:
+	//   - "severity>=WARN": the severity number is at least that of the named range,
+	//     TRACE, DEBUG, INFO, WARN, ERROR or FATAL, or at least the given number.
+	//   - "attr.<key>", "resource.<key>": the log record or resource attribute exists.
+	//   - "attr.<key>==<value>", "resource.<key>==<value>", "scope.name==<value>":
+	//     the attribute or scope name equals the value.
+	//   - "attr.<key>=~<regex>", "resource.<key>=~<regex>", "scope.name=~<regex>":
+	//     the attribute or scope name matches the regular expression.
+	//   - "body*=<text>": the body contains the text.
+	//
+	// For example "severity>=INFO;resource.service.name=~^checkout".
+	//
+	// Leave empty to process every record.
+	FilterInclude []string `env:"FILTER_INCLUDE, delimiter=;"`
+
+	// FilterExclude is the semicolon-separated list of rules dropping the log records
+	// matching any of them, in the same syntax as FilterInclude. For example
+	// "attr.http.route==/healthz;body*=heartbeat".
+	//
+	// Leave empty to drop no record.
+	FilterExclude []string `env:"FILTER_EXCLUDE, delimiter=;"`
+
+	// SamplingRatio is the fraction of log records, between 0 and 1, kept by the sampler
+	// after filtering.
+	//
+	// Records with a trace ID are sampled by a hash of it, so either all or none
+	// of the records of a trace are kept. Other records are sampled at random.
+	//
+	// Default is 1, which keeps every record. 0 drops every record, like a rate of 0 in SamplingRates.
+	SamplingRatio float64 `env:"SAMPLING_RATIO, default=1"`
+
+	// SamplingAttributeKey is the attribute key whose values select a sampling ratio
+	// from SamplingRates, e.g. "service.name". It is looked up like ValueAttributeKey.
+	//
+	// Leave empty to sample every record at SamplingRatio.
+	SamplingAttributeKey string `env:"SAMPLING_ATTRIBUTE_KEY"`
+
+	// SamplingRates maps values of SamplingAttributeKey to their sampling ratio,
+	// e.g. "chatty-service:0.01,noisy-service:0.1". Records with other values are
+	// sampled at SamplingRatio.
+	SamplingRates map[string]float64 `env:"SAMPLING_RATES"`
+
+	// SamplingScaleCounts enables scaling the aggregated counts back up by the inverse
+	// of the sampling ratio of each record, so they estimate the volume before sampling.
+	//
+	// Only counts are scaled, numeric values, distributions and severity breakdowns
+	// only cover the sampled records.
+	//
+	// Default is false.
+	SamplingScaleCounts bool `env:"SAMPLING_SCALE_COUNTS, default=false"`
+
+	// RedactPatterns is the comma-separated list of built-in patterns the "redact" processor
+	// redacts from log bodies and string attribute values of the log record, scope and resource.
+	//
+	// Supported patterns are:
+	//   - "email": email addresses
+	//   - "credit_card": card numbers of 13 to 19 digits, optionally grouped by spaces
+	//     or dashes, passing the Luhn check
+	//   - "ip": IPv4 and IPv6 addresses
+	//
+	// Default is "email,credit_card,ip".
+	RedactPatterns []string `env:"REDACT_PATTERNS, default=email,credit_card,ip"`
+
+	// RedactRegexes is the semicolon-separated list of additional regular expressions
+	// whose matches are redacted, e.g. "user_id=\d+;Bearer [\w.-]+".
+	RedactRegexes []string `env:"REDACT_REGEXES, delimiter=;"`
+
+	// RedactMode is how the "redact" processor replaces sensitive values.
+	//
+	// Supported values are:
+	//   - "mask": values are replaced by "[REDACTED]"
+	//   - "hash": values are replaced by "sha256:" and the first 16 hex digits of
+	//     their HMAC-SHA256 keyed with RedactHashSalt, so records with the same
+	//     value are still grouped and deduplicated together
+	//
+	// Default is "mask".
+	RedactMode string `env:"REDACT_MODE, default=mask"`
+
+	// RedactHashSalt is the secret salt of the hashes in the "hash" RedactMode,
+	// preventing the original values from being guessed by hashing candidates.
+	//
+	// It is required in the "hash" mode.
+	RedactHashSalt string `env:"REDACT_HASH_SALT"`
+
 	// Shards is the number of shards to use for the log aggregator.
 	//
 	// More shards can improve concurrency and performance when processing
@@ -41,6 +363,103 @@ type Config struct {
 	// The value should be a power of two for optimal performance.
 	Shards int `env:"SHARDS, default=32"`
 
+	// MaxKeysPerWindow is the maximum number of distinct attribute value
+	// combinations aggregated in a single window.
+	//
+	// Once the limit is reached, records with new combinations are counted into
+	// an "__overflow__" group instead, protecting the process from unbounded
+	// memory growth when a high-cardinality value ends up in an aggregation attribute.
+	//
+	// Default is 0, which means no limit.
+	MaxKeysPerWindow int `env:"MAX_KEYS_PER_WINDOW, default=0"`
+
+	// MaxKeysPerShard is the maximum number of distinct attribute value
+	// combinations held by a single aggregator shard in a window.
+	//
+	// It behaves like MaxKeysPerWindow, but is enforced per shard without any
+	// coordination between shards.
+	//
+	// Default is 0, which means no limit.
+	MaxKeysPerShard int `env:"MAX_KEYS_PER_SHARD, default=0"`
+
+	// AggregationMode is how the groups of a window are counted.
+	//
+	// Supported values are:
+	//   - "exact": counts every combination of attribute values exactly, subject to
+	//     MaxKeysPerWindow and MaxKeysPerShard.
+	//   - "topk": only reports the TopK most frequent combinations, tracked with a
+	//     Space-Saving summary of TopKCounters counters. Memory is bounded regardless
+	//     of cardinality, at the cost of counts overestimated by a reported error bound.
+	//     The cardinality limits do not apply.
+	//
+	// Default is "exact".
+	AggregationMode string `env:"AGGREGATION_MODE, default=exact"`
+
+	// TopK is the number of most frequent combinations reported per window in "topk" mode.
+	//
+	// Default is 100.
+	TopK int `env:"TOP_K, default=100"`
+
+	// TopKCounters is the number of counters tracking candidate combinations in "topk" mode,
+	// split evenly across shards. It must be at least TopK.
+	//
+	// More counters give tighter counts: the count of a combination is overestimated
+	// by at most the number of records of its shard divided by the counters per shard.
+	//
+	// Default is 0, which means 10 times TopK.
+	TopKCounters int `env:"TOP_K_COUNTERS, default=0"`
+
+	// DedupBackend is the data structure used to remember seen log records for deduplication.
+	//
+	// Supported values are:
+	//   - "exact": keeps the hash of every record, using memory proportional to the number of records
+	//   - "bloom": keeps the hashes in a scalable Bloom filter with bounded memory, at the cost of
+	//     reporting a small fraction of new records as duplicates (see DedupFalsePositiveRate)
+	//   - "none": disables deduplication, every record is aggregated
+	//
+	// Default is "exact".
+	DedupBackend string `env:"DEDUP_BACKEND, default=exact"`
+
+	// DedupFields is the comma-separated list of log record fields identifying a record for deduplication.
+	//
+	// Records with the same values for all the fields are considered duplicates.
+	// Supported values are:
+	//   - "attribute_values": the values of the AttributeKeys
+	//   - "time", "observed_time", "severity", "body", "trace_id" and "span_id"
+	//   - "attr.<key>": a log record attribute, e.g. "attr.log.record.uid"
+	//   - "resource.<key>": a resource attribute, e.g. "resource.host.name"
+	//
+	// Leaving out "observed_time" catches retries re-stamped by intermediate agents.
+	//
+	// Default is "attribute_values,time,observed_time,severity,body,trace_id,span_id".
+	DedupFields []string `env:"DEDUP_FIELDS, default=attribute_values,time,observed_time,severity,body,trace_id,span_id"`
+
+	// DedupFalsePositiveRate is the target probability of the bloom backend
+	// reporting a new record as a duplicate, between 0 and 1 exclusive.
+	//
+	// Default is 0.001.
+	DedupFalsePositiveRate float64 `env:"DEDUP_FALSE_POSITIVE_RATE, default=0.001"`
+
+	// DedupMaxMemory is the memory ceiling in bytes of the bloom backend, split evenly across shards.
+	//
+	// Once reached, the filters stop growing and the false positive rate
+	// rises above DedupFalsePositiveRate instead.
+	//
+	// Default is 64MB.
+	DedupMaxMemory int `env:"DEDUP_MAX_MEMORY, default=67108864"`
+
+	// DedupTTL is how long a seen log record is remembered for deduplication,
+	// independently of the aggregation window boundaries.
+	//
+	// Records are kept in two generations rotated every DedupTTL, so a record is
+	// remembered for between DedupTTL and twice that. Retries arriving within
+	// DedupTTL of the original record are always caught.
+	//
+	// Value should be a valid golang duration string (e.g., "30s", "5m").
+	//
+	// Default is 0, which means the AggregationWindow is used.
+	DedupTTL time.Duration `env:"DEDUP_TTL, default=0"`
+
 	// Workers is the number of worker goroutines to process logs.
 	//
 	// Each worker will read from the log processing queue and process logs concurrently.
@@ -56,6 +475,57 @@ type Config struct {
 	// Default is 1000.
 	QueueSize int `env:"QUEUE_SIZE, default=1000"`
 
+	// Exporters is the comma-separated list of sinks flushed aggregation windows are exported to.
+	//
+	// Supported values are "stdout", "file" and "otlp". Each exporter runs independently,
+	// so a slow or failing sink does not block the others.
+	//
+	// Default is "stdout".
+	Exporters []string `env:"EXPORTERS, default=stdout"`
+
+	// ExportQueueSize is the number of flushed windows buffered per exporter.
+	//
+	// When an exporter falls behind and its queue is full, new windows are dropped for that exporter only.
+	//
+	// Default is 16.
+	ExportQueueSize int `env:"EXPORT_QUEUE_SIZE, default=16"`
+
+	// ExportFilePath is the file the "file" exporter appends flushed windows to, one JSON object per line.
+	//
+	// Default is "windows.jsonl".
+	ExportFilePath string `env:"EXPORT_FILE_PATH, default=windows.jsonl"`
+
+	// ExportOTLPEndpoint is the gRPC endpoint of a downstream collector
+	// that flushed aggregation windows are exported to as OTLP metrics.
+	//
+	// For example "otel-collector:4317". Required when Exporters contains "otlp".
+	ExportOTLPEndpoint string `env:"EXPORT_OTLP_ENDPOINT"`
+
+	// ExportOTLPInsecure disables TLS when connecting to ExportOTLPEndpoint.
+	//
+	// Default is false.
+	ExportOTLPInsecure bool `env:"EXPORT_OTLP_INSECURE, default=false"`
+
+	// ExportOTLPTimeout is the timeout for a single export attempt.
+	//
+	// Default is 5s.
+	ExportOTLPTimeout time.Duration `env:"EXPORT_OTLP_TIMEOUT, default=5s"`
+
+	// ExportOTLPMaxRetries is the maximum number of retries for a failed export
+	// before the window is dropped.
+	//
+	// Only retryable errors (e.g. Unavailable, ResourceExhausted) are retried.
+	//
+	// Default is 3.
+	ExportOTLPMaxRetries int `env:"EXPORT_OTLP_MAX_RETRIES, default=3"`
+
+	// ExportOTLPRetryBackoff is the initial backoff between export retries.
+	//
+	// The backoff doubles after every failed attempt.
+	//
+	// Default is 500ms.
+	ExportOTLPRetryBackoff time.Duration `env:"EXPORT_OTLP_RETRY_BACKOFF, default=500ms"`
+
 	// OtelEnabled indicates whether OpenTelemetry instrumentation is enabled.
 	//
 	// Default is true.
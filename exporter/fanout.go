@@ -0,0 +1,171 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/miguelhrocha/otel-collector/config"
+	"github.com/miguelhrocha/otel-collector/ingestor"
+	"github.com/miguelhrocha/otel-collector/metrics"
+)
+
+// Fanout exports flushed aggregation windows to multiple exporters.
+//
+// Every exporter gets its own queue and worker goroutine, so a slow or failing
+// exporter neither blocks the other exporters nor the WindowManager flushing
+// the next window. When an exporter's queue is full, windows are dropped for
+// that exporter only.
+//
+// Use NewFanout or New to create a new Fanout and Close to stop it.
+type Fanout struct {
+	sinks []*sink
+	wg    sync.WaitGroup
+}
+
+type sink struct {
+	name     string
+	exporter ingestor.Exporter
+	queue    chan ingestor.WindowResult
+}
+
+// New creates a Fanout with the exporters listed in the config's Exporters field.
+//
+// It returns an error if an exporter is unknown or fails to start.
+func New(cfg config.Config) (*Fanout, error) {
+	exporters := make(map[string]ingestor.Exporter, len(cfg.Exporters))
+
+	// closeAll releases the exporters created so far if a later one fails.
+	closeAll := func() {
+		for _, e := range exporters {
+			if c, ok := e.(io.Closer); ok {
+				_ = c.Close()
+			}
+		}
+	}
+
+	for _, name := range cfg.Exporters {
+		if _, ok := exporters[name]; ok {
+			continue
+		}
+
+		var (
+			e   ingestor.Exporter
+			err error
+		)
+
+		switch name {
+		case "stdout":
+			e = NewStdoutExporter()
+		case "file":
+			e, err = NewFileExporter(cfg.ExportFilePath)
+		case "otlp":
+			if cfg.ExportOTLPEndpoint == "" {
+				err = errors.New("EXPORT_OTLP_ENDPOINT is required for the otlp exporter")
+			} else {
+				e, err = NewOTLPExporter(cfg)
+			}
+		default:
+			err = fmt.Errorf("unknown exporter %q", name)
+		}
+
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		exporters[name] = e
+	}
+
+	return NewFanout(cfg.ExportQueueSize, exporters), nil
+}
+
+// NewFanout creates a Fanout exporting to the given exporters, keyed by name.
+//
+// It starts one worker goroutine per exporter, each with a queue of queueSize windows.
+func NewFanout(queueSize int, exporters map[string]ingestor.Exporter) *Fanout {
+	if queueSize <= 0 {
+		queueSize = 1
+	}
+
+	f := &Fanout{}
+	for name, e := range exporters {
+		s := &sink{
+			name:     name,
+			exporter: e,
+			queue:    make(chan ingestor.WindowResult, queueSize),
+		}
+		f.sinks = append(f.sinks, s)
+
+		f.wg.Add(1)
+		go func() {
+			defer f.wg.Done()
+			for result := range s.queue {
+				s.export(result)
+			}
+		}()
+	}
+
+	return f
+}
+
+// Export enqueues the window for every exporter.
+//
+// This method is non-blocking. Windows that cannot be enqueued are dropped and counted in metrics.
+func (f *Fanout) Export(ctx context.Context, result ingestor.WindowResult) error {
+	for _, s := range f.sinks {
+		select {
+		case s.queue <- result:
+		default:
+			metrics.ExportDropped.Add(ctx, 1, metric.WithAttributes(attribute.String("exporter", s.name)))
+			slog.WarnContext(ctx, "Exporter queue is full, dropping window", slog.String("exporter", s.name))
+		}
+	}
+	return nil
+}
+
+// Close stops accepting windows, waits for the queued windows to be exported,
+// and closes the exporters that hold resources.
+//
+// Export must not be called after Close.
+func (f *Fanout) Close() error {
+	for _, s := range f.sinks {
+		close(s.queue)
+	}
+	f.wg.Wait()
+
+	var err error
+	for _, s := range f.sinks {
+		if c, ok := s.exporter.(io.Closer); ok {
+			err = errors.Join(err, c.Close())
+		}
+	}
+	return err
+}
+
+// export runs a single export, isolating the worker from exporter errors and panics.
+func (s *sink) export(result ingestor.WindowResult) {
+	// Workers outlive the request that produced the window, so they use their own context.
+	ctx := context.Background()
+
+	defer func() {
+		if r := recover(); r != nil {
+			metrics.ExportFailures.Add(ctx, 1, metric.WithAttributes(attribute.String("exporter", s.name)))
+			slog.ErrorContext(ctx, "Exporter panicked",
+				slog.String("exporter", s.name),
+				slog.Any("panic", r))
+		}
+	}()
+
+	if err := s.exporter.Export(ctx, result); err != nil {
+		metrics.ExportFailures.Add(ctx, 1, metric.WithAttributes(attribute.String("exporter", s.name)))
+		slog.ErrorContext(ctx, "Failed to export aggregation window",
+			slog.String("exporter", s.name),
+			slog.Any("error", err))
+	}
+}
@@ -0,0 +1,100 @@
+package exporter_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+
+	"github.com/miguelhrocha/otel-collector/exporter"
+	"github.com/miguelhrocha/otel-collector/ingestor"
+	"github.com/miguelhrocha/otel-collector/metrics"
+)
+
+type recordingExporter struct {
+	mu      sync.Mutex
+	results []ingestor.WindowResult
+}
+
+func (e *recordingExporter) Export(_ context.Context, r ingestor.WindowResult) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.results = append(e.results, r)
+	return nil
+}
+
+type failingExporter struct{}
+
+func (failingExporter) Export(context.Context, ingestor.WindowResult) error {
+	return errors.New("sink is down")
+}
+
+type panickingExporter struct{}
+
+func (panickingExporter) Export(context.Context, ingestor.WindowResult) error {
+	panic("sink exploded")
+}
+
+type blockingExporter struct {
+	release chan struct{}
+}
+
+func (e blockingExporter) Export(context.Context, ingestor.WindowResult) error {
+	<-e.release
+	return nil
+}
+
+func TestFanout(t *testing.T) {
+	require.NoError(t, metrics.InitMetrics(otel.Meter("test")))
+
+	t.Run("isolates failing exporters", func(t *testing.T) {
+		healthy := &recordingExporter{}
+		fanout := exporter.NewFanout(4, map[string]ingestor.Exporter{
+			"healthy":   healthy,
+			"failing":   failingExporter{},
+			"panicking": panickingExporter{},
+		})
+
+		for i := range 3 {
+			err := fanout.Export(context.Background(), ingestor.WindowResult{Groups: []ingestor.Group{{Values: []string{"foo"}, Count: int64(i)}}})
+			require.NoError(t, err)
+		}
+		require.NoError(t, fanout.Close())
+
+		assert.Len(t, healthy.results, 3)
+	})
+
+	t.Run("slow exporters do not block the others", func(t *testing.T) {
+		release := make(chan struct{})
+		healthy := &recordingExporter{}
+		fanout := exporter.NewFanout(1, map[string]ingestor.Exporter{
+			"healthy": healthy,
+			"blocked": blockingExporter{release: release},
+		})
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for range 5 {
+				_ = fanout.Export(context.Background(), ingestor.WindowResult{})
+				time.Sleep(10 * time.Millisecond)
+			}
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("Export blocked on a slow exporter")
+		}
+
+		close(release)
+		require.NoError(t, fanout.Close())
+
+		assert.Len(t, healthy.results, 5)
+	})
+}
@@ -0,0 +1,167 @@
+package exporter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/miguelhrocha/otel-collector/ingestor"
+)
+
+// FileExporter appends flushed aggregation windows to a file as JSON lines.
+//
+// Use NewFileExporter to create a new FileExporter and Close to close the file.
+type FileExporter struct {
+	mu   sync.Mutex
+	file *os.File
+	enc  *json.Encoder
+}
+
+// fileWindow is the JSON representation of a flushed window.
+type fileWindow struct {
+	Start         time.Time     `json:"start"`
+	End           time.Time     `json:"end"`
+	AttributeKeys []string      `json:"attribute_keys"`
+	Groups        []fileGroup   `json:"groups"`
+	Overflow      *fileOverflow `json:"overflow,omitempty"`
+	TopK          *fileTopK     `json:"top_k,omitempty"`
+	Correction    bool          `json:"correction,omitempty"`
+	LateRecords   int64         `json:"late_records,omitempty"`
+}
+
+type fileTopK struct {
+	K          int   `json:"k"`
+	Records    int64 `json:"records"`
+	ErrorBound int64 `json:"error_bound"`
+}
+
+type fileOverflow struct {
+	Records int64 `json:"records"`
+	Keys    int64 `json:"keys"`
+}
+
+type fileGroup struct {
+	Values []string   `json:"values"`
+	Count  int64      `json:"count"`
+	Value  *fileValue `json:"value,omitempty"`
+
+	CountError int64            `json:"count_error,omitempty"`
+	Severities map[string]int64 `json:"severities,omitempty"`
+
+	Distribution *fileDistribution `json:"distribution,omitempty"`
+	Distinct     *fileDistinct     `json:"distinct,omitempty"`
+}
+
+type fileDistinct struct {
+	Key      string `json:"key"`
+	Estimate int64  `json:"estimate"`
+}
+
+type fileDistribution struct {
+	Key       string         `json:"key"`
+	Count     uint64         `json:"count"`
+	Sum       float64        `json:"sum"`
+	Min       float64        `json:"min"`
+	Max       float64        `json:"max"`
+	Quantiles []fileQuantile `json:"quantiles"`
+}
+
+type fileQuantile struct {
+	Quantile float64 `json:"quantile"`
+	Value    float64 `json:"value"`
+}
+
+type fileValue struct {
+	Key   string  `json:"key"`
+	Count int64   `json:"count"`
+	Sum   float64 `json:"sum"`
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Mean  float64 `json:"mean"`
+}
+
+// NewFileExporter creates a new FileExporter appending to the file at path.
+//
+// The file is created if it does not exist.
+func NewFileExporter(path string) (*FileExporter, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("opening export file: %w", err)
+	}
+
+	return &FileExporter{
+		file: f,
+		enc:  json.NewEncoder(f),
+	}, nil
+}
+
+// Export appends the window to the file as a single JSON line.
+func (e *FileExporter) Export(_ context.Context, result ingestor.WindowResult) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	groups := make([]fileGroup, len(result.Groups))
+	for i, g := range result.Groups {
+		groups[i] = fileGroup{Values: g.Values, Count: g.Count, CountError: g.CountError}
+		if v := g.Value; v.Count > 0 {
+			groups[i].Value = &fileValue{
+				Key:   result.ValueKey,
+				Count: v.Count,
+				Sum:   v.Sum,
+				Min:   v.Min,
+				Max:   v.Max,
+				Mean:  v.Mean(),
+			}
+		}
+		if h := g.Distribution; h != nil {
+			d := &fileDistribution{
+				Key:       result.DistributionKey,
+				Count:     h.Count,
+				Sum:       h.Sum,
+				Min:       h.Min,
+				Max:       h.Max,
+				Quantiles: make([]fileQuantile, len(result.Quantiles)),
+			}
+			for j, q := range result.Quantiles {
+				d.Quantiles[j] = fileQuantile{Quantile: q, Value: h.Quantile(q)}
+			}
+			groups[i].Distribution = d
+		}
+		if result.SeverityBreakdown {
+			groups[i].Severities = make(map[string]int64)
+			for s, n := range g.Severities {
+				if n > 0 {
+					groups[i].Severities[ingestor.SeverityRange(s).String()] = n
+				}
+			}
+		}
+		if g.Distinct > 0 {
+			groups[i].Distinct = &fileDistinct{Key: result.DistinctKey, Estimate: g.Distinct}
+		}
+	}
+
+	window := fileWindow{
+		Start:         result.Start,
+		End:           result.End,
+		AttributeKeys: result.AttributeKeys,
+		Groups:        groups,
+		Correction:    result.Correction,
+		LateRecords:   result.LateRecords,
+	}
+	if result.Overflow.Records > 0 {
+		window.Overflow = &fileOverflow{Records: result.Overflow.Records, Keys: result.Overflow.Keys}
+	}
+	if t := result.TopK; t != nil {
+		window.TopK = &fileTopK{K: t.K, Records: t.Records, ErrorBound: t.ErrorBound}
+	}
+
+	return e.enc.Encode(window)
+}
+
+// Close closes the underlying file.
+func (e *FileExporter) Close() error {
+	return e.file.Close()
+}
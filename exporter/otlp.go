@@ -0,0 +1,432 @@
+package exporter
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"time"
+
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	"github.com/miguelhrocha/otel-collector/config"
+	"github.com/miguelhrocha/otel-collector/ingestor"
+)
+
+const (
+	scopeName = "miguelhrocha.com/otel-collector"
+
+	// MetricName is the name of the OTLP Sum metric holding the aggregated log counts.
+	MetricName = "log.records"
+
+	// CountErrorMetricName is the name of the OTLP Gauge metric holding the maximum
+	// overestimation of the aggregated log counts in top-K mode.
+	CountErrorMetricName = "log.records.count_error"
+
+	// SeverityMetricName is the name of the OTLP Sum metric holding the aggregated log counts
+	// per severity range, attributed with the range's name under the "severity" key.
+	SeverityMetricName = "log.records.severity"
+)
+
+// OTLPExporter exports flushed aggregation windows as OTLP metrics,
+// pushed to a downstream collector through the gRPC MetricsService/Export method.
+//
+// Use NewOTLPExporter to create a new OTLPExporter and Close to release the connection.
+type OTLPExporter struct {
+	conn   *grpc.ClientConn
+	client colmetricspb.MetricsServiceClient
+
+	timeout    time.Duration
+	maxRetries int
+	backoff    time.Duration
+}
+
+// NewOTLPExporter creates a new OTLPExporter connected to the config's ExportOTLPEndpoint.
+//
+// The connection is established lazily, so an unavailable endpoint does not
+// prevent the exporter from being created. Sliding windows are rejected, as
+// downstream sums of their overlapping deltas would count each record once per window.
+func NewOTLPExporter(cfg config.Config) (*OTLPExporter, error) {
+	if cfg.WindowSlide > 0 && cfg.WindowSlide < cfg.AggregationWindow {
+		return nil, fmt.Errorf("the OTLP exporter does not support sliding windows, got a window slide of %s for a %s window", cfg.WindowSlide, cfg.AggregationWindow)
+	}
+
+	creds := credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12})
+	if cfg.ExportOTLPInsecure {
+		creds = insecure.NewCredentials()
+	}
+
+	conn, err := grpc.NewClient(cfg.ExportOTLPEndpoint, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, fmt.Errorf("creating OTLP exporter client: %w", err)
+	}
+
+	return &OTLPExporter{
+		conn:       conn,
+		client:     colmetricspb.NewMetricsServiceClient(conn),
+		timeout:    cfg.ExportOTLPTimeout,
+		maxRetries: cfg.ExportOTLPMaxRetries,
+		backoff:    cfg.ExportOTLPRetryBackoff,
+	}, nil
+}
+
+// Export pushes the counts of the window to the downstream collector.
+//
+// Retryable failures are retried with exponential backoff up to the configured
+// number of retries. Empty windows are not exported.
+func (e *OTLPExporter) Export(ctx context.Context, result ingestor.WindowResult) error {
+	if len(result.Groups) == 0 {
+		return nil
+	}
+
+	request := buildRequest(result)
+	backoff := e.backoff
+
+	for attempt := 0; ; attempt++ {
+		err := e.export(ctx, request)
+		if err == nil {
+			return nil
+		}
+
+		if attempt >= e.maxRetries || !isRetryable(err) {
+			return fmt.Errorf("exporting window after %d attempts: %w", attempt+1, err)
+		}
+
+		slog.WarnContext(ctx, "OTLP export failed, retrying",
+			slog.Int("attempt", attempt+1),
+			slog.Duration("backoff", backoff),
+			slog.Any("error", err))
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+// Close closes the connection to the downstream collector.
+func (e *OTLPExporter) Close() error {
+	return e.conn.Close()
+}
+
+func (e *OTLPExporter) export(ctx context.Context, request *colmetricspb.ExportMetricsServiceRequest) error {
+	if e.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.timeout)
+		defer cancel()
+	}
+
+	response, err := e.client.Export(ctx, request)
+	if err != nil {
+		return err
+	}
+
+	if ps := response.GetPartialSuccess(); ps != nil && ps.GetRejectedDataPoints() > 0 {
+		slog.WarnContext(ctx, "OTLP export partially rejected",
+			slog.Int64("rejected_data_points", ps.GetRejectedDataPoints()),
+			slog.String("error_message", ps.GetErrorMessage()))
+	}
+
+	return nil
+}
+
+// buildRequest converts a window into a delta Sum named MetricName, with one data point
+// per group attributed with the group's values, followed by the metrics of its
+// optional aggregations.
+//
+// Corrections of event-time windows are exported as additional deltas over
+// the same interval, so downstream sums include the late records.
+func buildRequest(result ingestor.WindowResult) *colmetricspb.ExportMetricsServiceRequest {
+	dataPoints := make([]*metricspb.NumberDataPoint, 0, len(result.Groups))
+	for _, g := range result.Groups {
+		dataPoints = append(dataPoints, &metricspb.NumberDataPoint{
+			Attributes:        groupAttributes(result.AttributeKeys, g),
+			StartTimeUnixNano: uint64(result.Start.UnixNano()),
+			TimeUnixNano:      uint64(result.End.UnixNano()),
+			Value:             &metricspb.NumberDataPoint_AsInt{AsInt: g.Count},
+		})
+	}
+
+	metrics := []*metricspb.Metric{
+		{
+			Name:        MetricName,
+			Description: "The number of deduplicated log records per combination of attribute values in an aggregation window",
+			Unit:        "{log}",
+			Data: &metricspb.Metric_Sum{
+				Sum: &metricspb.Sum{
+					DataPoints:             dataPoints,
+					AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA,
+					IsMonotonic:            true,
+				},
+			},
+		},
+	}
+	if m := valueMetric(result); m != nil {
+		metrics = append(metrics, m)
+	}
+	if m := distributionMetric(result); m != nil {
+		metrics = append(metrics, m)
+	}
+	if m := distinctMetric(result); m != nil {
+		metrics = append(metrics, m)
+	}
+	if m := countErrorMetric(result); m != nil {
+		metrics = append(metrics, m)
+	}
+	if m := severityMetric(result); m != nil {
+		metrics = append(metrics, m)
+	}
+
+	return &colmetricspb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricspb.ResourceMetrics{
+			{
+				Resource: &resourcepb.Resource{
+					Attributes: []*commonpb.KeyValue{stringAttribute("service.name", "otlp-log-processor")},
+				},
+				ScopeMetrics: []*metricspb.ScopeMetrics{
+					{
+						Scope:   &commonpb.InstrumentationScope{Name: scopeName},
+						Metrics: metrics,
+					},
+				},
+			},
+		},
+	}
+}
+
+// valueMetric returns the delta Histogram of the groups' numeric values, named after
+// the value key, or nil if the window has no values.
+//
+// Each data point holds the count, sum, min and max of a group without buckets.
+func valueMetric(result ingestor.WindowResult) *metricspb.Metric {
+	if result.ValueKey == "" {
+		return nil
+	}
+
+	var dataPoints []*metricspb.HistogramDataPoint
+	for _, g := range result.Groups {
+		v := g.Value
+		if v.Count == 0 {
+			continue
+		}
+
+		dataPoints = append(dataPoints, &metricspb.HistogramDataPoint{
+			Attributes:        groupAttributes(result.AttributeKeys, g),
+			StartTimeUnixNano: uint64(result.Start.UnixNano()),
+			TimeUnixNano:      uint64(result.End.UnixNano()),
+			Count:             uint64(v.Count),
+			Sum:               &v.Sum,
+			Min:               &v.Min,
+			Max:               &v.Max,
+			// A single bucket without bounds holds every value.
+			BucketCounts: []uint64{uint64(v.Count)},
+		})
+	}
+	if len(dataPoints) == 0 {
+		return nil
+	}
+
+	return &metricspb.Metric{
+		Name:        result.ValueKey,
+		Description: "The values of the " + result.ValueKey + " attribute per combination of attribute values in an aggregation window",
+		Data: &metricspb.Metric_Histogram{
+			Histogram: &metricspb.Histogram{
+				DataPoints:             dataPoints,
+				AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA,
+			},
+		},
+	}
+}
+
+// distributionMetric returns the delta ExponentialHistogram of the groups' distributions,
+// named after the distribution key, or nil if the window has none.
+func distributionMetric(result ingestor.WindowResult) *metricspb.Metric {
+	if result.DistributionKey == "" {
+		return nil
+	}
+
+	var dataPoints []*metricspb.ExponentialHistogramDataPoint
+	for _, g := range result.Groups {
+		h := g.Distribution
+		if h == nil {
+			continue
+		}
+
+		dataPoints = append(dataPoints, &metricspb.ExponentialHistogramDataPoint{
+			Attributes:        groupAttributes(result.AttributeKeys, g),
+			StartTimeUnixNano: uint64(result.Start.UnixNano()),
+			TimeUnixNano:      uint64(result.End.UnixNano()),
+			Count:             h.Count,
+			Sum:               &h.Sum,
+			Min:               &h.Min,
+			Max:               &h.Max,
+			Scale:             h.Scale,
+			ZeroCount:         h.ZeroCount,
+			Positive:          &metricspb.ExponentialHistogramDataPoint_Buckets{Offset: h.Positive.Offset, BucketCounts: h.Positive.Counts},
+			Negative:          &metricspb.ExponentialHistogramDataPoint_Buckets{Offset: h.Negative.Offset, BucketCounts: h.Negative.Counts},
+		})
+	}
+	if len(dataPoints) == 0 {
+		return nil
+	}
+
+	return &metricspb.Metric{
+		Name:        result.DistributionKey,
+		Description: "The distribution of the " + result.DistributionKey + " attribute per combination of attribute values in an aggregation window",
+		Data: &metricspb.Metric_ExponentialHistogram{
+			ExponentialHistogram: &metricspb.ExponentialHistogram{
+				DataPoints:             dataPoints,
+				AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA,
+			},
+		},
+	}
+}
+
+// distinctMetric returns the Gauge of the groups' estimated distinct counts, named
+// after the distinct key with a ".distinct" suffix, or nil if the window has none.
+//
+// Distinct counts of consecutive windows cannot be added up, so they are
+// reported as a gauge rather than a sum.
+func distinctMetric(result ingestor.WindowResult) *metricspb.Metric {
+	if result.DistinctKey == "" {
+		return nil
+	}
+
+	var dataPoints []*metricspb.NumberDataPoint
+	for _, g := range result.Groups {
+		if g.Distinct == 0 {
+			continue
+		}
+
+		dataPoints = append(dataPoints, &metricspb.NumberDataPoint{
+			Attributes:        groupAttributes(result.AttributeKeys, g),
+			StartTimeUnixNano: uint64(result.Start.UnixNano()),
+			TimeUnixNano:      uint64(result.End.UnixNano()),
+			Value:             &metricspb.NumberDataPoint_AsInt{AsInt: g.Distinct},
+		})
+	}
+	if len(dataPoints) == 0 {
+		return nil
+	}
+
+	return &metricspb.Metric{
+		Name:        result.DistinctKey + ".distinct",
+		Description: "The estimated number of distinct values of the " + result.DistinctKey + " attribute per combination of attribute values in an aggregation window",
+		Data: &metricspb.Metric_Gauge{
+			Gauge: &metricspb.Gauge{DataPoints: dataPoints},
+		},
+	}
+}
+
+// countErrorMetric returns the Gauge of the groups' count errors in top-K mode,
+// named CountErrorMetricName, or nil in exact mode.
+func countErrorMetric(result ingestor.WindowResult) *metricspb.Metric {
+	if result.TopK == nil {
+		return nil
+	}
+
+	dataPoints := make([]*metricspb.NumberDataPoint, 0, len(result.Groups))
+	for _, g := range result.Groups {
+		dataPoints = append(dataPoints, &metricspb.NumberDataPoint{
+			Attributes:        groupAttributes(result.AttributeKeys, g),
+			StartTimeUnixNano: uint64(result.Start.UnixNano()),
+			TimeUnixNano:      uint64(result.End.UnixNano()),
+			Value:             &metricspb.NumberDataPoint_AsInt{AsInt: g.CountError},
+		})
+	}
+
+	return &metricspb.Metric{
+		Name:        CountErrorMetricName,
+		Description: "The maximum overestimation of the number of log records per combination of attribute values in a top-K aggregation window",
+		Unit:        "{log}",
+		Data: &metricspb.Metric_Gauge{
+			Gauge: &metricspb.Gauge{DataPoints: dataPoints},
+		},
+	}
+}
+
+// severityMetric returns the delta Sum of the groups' counts per severity range,
+// named SeverityMetricName, or nil if the breakdown is disabled.
+func severityMetric(result ingestor.WindowResult) *metricspb.Metric {
+	if !result.SeverityBreakdown {
+		return nil
+	}
+
+	var dataPoints []*metricspb.NumberDataPoint
+	for _, g := range result.Groups {
+		for s, n := range g.Severities {
+			if n == 0 {
+				continue
+			}
+
+			attributes := append(groupAttributes(result.AttributeKeys, g), stringAttribute("severity", ingestor.SeverityRange(s).String()))
+			dataPoints = append(dataPoints, &metricspb.NumberDataPoint{
+				Attributes:        attributes,
+				StartTimeUnixNano: uint64(result.Start.UnixNano()),
+				TimeUnixNano:      uint64(result.End.UnixNano()),
+				Value:             &metricspb.NumberDataPoint_AsInt{AsInt: n},
+			})
+		}
+	}
+	if len(dataPoints) == 0 {
+		return nil
+	}
+
+	return &metricspb.Metric{
+		Name:        SeverityMetricName,
+		Description: "The number of deduplicated log records per combination of attribute values and severity range in an aggregation window",
+		Unit:        "{log}",
+		Data: &metricspb.Metric_Sum{
+			Sum: &metricspb.Sum{
+				DataPoints:             dataPoints,
+				AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA,
+				IsMonotonic:            true,
+			},
+		},
+	}
+}
+
+// groupAttributes returns one attribute per attribute key holding the group's value for that key.
+func groupAttributes(keys []string, g ingestor.Group) []*commonpb.KeyValue {
+	attributes := make([]*commonpb.KeyValue, 0, len(keys))
+	for i, key := range keys {
+		if i < len(g.Values) {
+			attributes = append(attributes, stringAttribute(key, g.Values[i]))
+		}
+	}
+	return attributes
+}
+
+func stringAttribute(key, value string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{
+		Key:   key,
+		Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}},
+	}
+}
+
+// isRetryable reports whether an export error is transient,
+// following the OTLP specification for retryable gRPC status codes.
+func isRetryable(err error) bool {
+	switch status.Code(err) {
+	case codes.Canceled,
+		codes.DeadlineExceeded,
+		codes.Aborted,
+		codes.OutOfRange,
+		codes.Unavailable,
+		codes.DataLoss,
+		codes.ResourceExhausted:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,327 @@
+package exporter_test
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/miguelhrocha/otel-collector/config"
+	"github.com/miguelhrocha/otel-collector/exporter"
+	"github.com/miguelhrocha/otel-collector/ingestor"
+)
+
+type fakeMetricsServer struct {
+	mu       sync.Mutex
+	failures int
+	requests []*colmetricspb.ExportMetricsServiceRequest
+
+	colmetricspb.UnimplementedMetricsServiceServer
+}
+
+func (s *fakeMetricsServer) Export(_ context.Context, r *colmetricspb.ExportMetricsServiceRequest) (*colmetricspb.ExportMetricsServiceResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.failures > 0 {
+		s.failures--
+		return nil, status.Error(codes.Unavailable, "try again")
+	}
+
+	s.requests = append(s.requests, r)
+	return &colmetricspb.ExportMetricsServiceResponse{}, nil
+}
+
+func startMetricsServer(t *testing.T, srv *fakeMetricsServer) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	grpcServer := grpc.NewServer()
+	colmetricspb.RegisterMetricsServiceServer(grpcServer, srv)
+	go func() { _ = grpcServer.Serve(listener) }()
+	t.Cleanup(grpcServer.Stop)
+
+	return listener.Addr().String()
+}
+
+func TestOTLPExporter(t *testing.T) {
+	start := time.Unix(100, 0)
+	end := time.Unix(110, 0)
+	result := ingestor.WindowResult{
+		Start:         start,
+		End:           end,
+		AttributeKeys: []string{"foo", "status"},
+		Groups:        []ingestor.Group{{Values: []string{"bar", "200"}, Count: 3}},
+	}
+
+	t.Run("exports counts as a delta sum", func(t *testing.T) {
+		srv := &fakeMetricsServer{}
+		e, err := exporter.NewOTLPExporter(config.Config{
+			ExportOTLPEndpoint: startMetricsServer(t, srv),
+			ExportOTLPInsecure: true,
+			ExportOTLPTimeout:  time.Second,
+		})
+		require.NoError(t, err)
+		defer e.Close()
+
+		err = e.Export(context.Background(), result)
+		require.NoError(t, err)
+
+		require.Len(t, srv.requests, 1)
+		metric := srv.requests[0].GetResourceMetrics()[0].GetScopeMetrics()[0].GetMetrics()[0]
+		assert.Equal(t, exporter.MetricName, metric.GetName())
+
+		dataPoints := metric.GetSum().GetDataPoints()
+		require.Len(t, dataPoints, 1)
+		attributes := dataPoints[0].GetAttributes()
+		require.Len(t, attributes, 2)
+		assert.Equal(t, "foo", attributes[0].GetKey())
+		assert.Equal(t, "bar", attributes[0].GetValue().GetStringValue())
+		assert.Equal(t, "status", attributes[1].GetKey())
+		assert.Equal(t, "200", attributes[1].GetValue().GetStringValue())
+		assert.Equal(t, int64(3), dataPoints[0].GetAsInt())
+		assert.Equal(t, uint64(start.UnixNano()), dataPoints[0].GetStartTimeUnixNano())
+		assert.Equal(t, uint64(end.UnixNano()), dataPoints[0].GetTimeUnixNano())
+	})
+
+	t.Run("exports values as a delta histogram", func(t *testing.T) {
+		srv := &fakeMetricsServer{}
+		e, err := exporter.NewOTLPExporter(config.Config{
+			ExportOTLPEndpoint: startMetricsServer(t, srv),
+			ExportOTLPInsecure: true,
+			ExportOTLPTimeout:  time.Second,
+		})
+		require.NoError(t, err)
+		defer e.Close()
+
+		withValues := result
+		withValues.ValueKey = "duration_ms"
+		withValues.Groups = []ingestor.Group{
+			{Values: []string{"bar", "200"}, Count: 3, Value: ingestor.ValueStats{Count: 2, Sum: 30, Min: 10, Max: 20}},
+			{Values: []string{"bar", "500"}, Count: 1},
+		}
+
+		err = e.Export(context.Background(), withValues)
+		require.NoError(t, err)
+
+		require.Len(t, srv.requests, 1)
+		metrics := srv.requests[0].GetResourceMetrics()[0].GetScopeMetrics()[0].GetMetrics()
+		require.Len(t, metrics, 2)
+		assert.Equal(t, "duration_ms", metrics[1].GetName())
+
+		dataPoints := metrics[1].GetHistogram().GetDataPoints()
+		require.Len(t, dataPoints, 1, "Groups without values have no data point")
+		assert.Equal(t, uint64(2), dataPoints[0].GetCount())
+		assert.Equal(t, 30.0, dataPoints[0].GetSum())
+		assert.Equal(t, 10.0, dataPoints[0].GetMin())
+		assert.Equal(t, 20.0, dataPoints[0].GetMax())
+	})
+
+	t.Run("exports distributions as a delta exponential histogram", func(t *testing.T) {
+		srv := &fakeMetricsServer{}
+		e, err := exporter.NewOTLPExporter(config.Config{
+			ExportOTLPEndpoint: startMetricsServer(t, srv),
+			ExportOTLPInsecure: true,
+			ExportOTLPTimeout:  time.Second,
+		})
+		require.NoError(t, err)
+		defer e.Close()
+
+		withDistributions := result
+		withDistributions.DistributionKey = "latency_ms"
+		withDistributions.Groups = []ingestor.Group{
+			{Values: []string{"bar", "200"}, Count: 3, Distribution: &ingestor.ExponentialHistogram{
+				Scale:     3,
+				ZeroCount: 1,
+				Positive:  ingestor.ExponentialBuckets{Offset: 26, Counts: []uint64{1, 0, 1}},
+				Count:     3,
+				Sum:       18,
+				Max:       10,
+			}},
+			{Values: []string{"bar", "500"}, Count: 1},
+		}
+
+		err = e.Export(context.Background(), withDistributions)
+		require.NoError(t, err)
+
+		require.Len(t, srv.requests, 1)
+		metrics := srv.requests[0].GetResourceMetrics()[0].GetScopeMetrics()[0].GetMetrics()
+		require.Len(t, metrics, 2)
+		assert.Equal(t, "latency_ms", metrics[1].GetName())
+
+		histogram := metrics[1].GetExponentialHistogram()
+		assert.Equal(t, metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA, histogram.GetAggregationTemporality())
+
+		dataPoints := histogram.GetDataPoints()
+		require.Len(t, dataPoints, 1, "Groups without distributions have no data point")
+		assert.Equal(t, int32(3), dataPoints[0].GetScale())
+		assert.Equal(t, uint64(1), dataPoints[0].GetZeroCount())
+		assert.Equal(t, int32(26), dataPoints[0].GetPositive().GetOffset())
+		assert.Equal(t, []uint64{1, 0, 1}, dataPoints[0].GetPositive().GetBucketCounts())
+		assert.Equal(t, uint64(3), dataPoints[0].GetCount())
+		assert.Equal(t, 18.0, dataPoints[0].GetSum())
+		assert.Equal(t, 10.0, dataPoints[0].GetMax())
+	})
+
+	t.Run("exports distinct counts as a gauge", func(t *testing.T) {
+		srv := &fakeMetricsServer{}
+		e, err := exporter.NewOTLPExporter(config.Config{
+			ExportOTLPEndpoint: startMetricsServer(t, srv),
+			ExportOTLPInsecure: true,
+			ExportOTLPTimeout:  time.Second,
+		})
+		require.NoError(t, err)
+		defer e.Close()
+
+		withDistinct := result
+		withDistinct.DistinctKey = "user.id"
+		withDistinct.Groups = []ingestor.Group{
+			{Values: []string{"bar", "200"}, Count: 3, Distinct: 2},
+			{Values: []string{"bar", "500"}, Count: 1},
+		}
+
+		err = e.Export(context.Background(), withDistinct)
+		require.NoError(t, err)
+
+		require.Len(t, srv.requests, 1)
+		metrics := srv.requests[0].GetResourceMetrics()[0].GetScopeMetrics()[0].GetMetrics()
+		require.Len(t, metrics, 2)
+		assert.Equal(t, "user.id.distinct", metrics[1].GetName())
+
+		dataPoints := metrics[1].GetGauge().GetDataPoints()
+		require.Len(t, dataPoints, 1, "Groups without distinct values have no data point")
+		assert.Equal(t, int64(2), dataPoints[0].GetAsInt())
+	})
+
+	t.Run("exports count errors in top-K mode", func(t *testing.T) {
+		srv := &fakeMetricsServer{}
+		e, err := exporter.NewOTLPExporter(config.Config{
+			ExportOTLPEndpoint: startMetricsServer(t, srv),
+			ExportOTLPInsecure: true,
+			ExportOTLPTimeout:  time.Second,
+		})
+		require.NoError(t, err)
+		defer e.Close()
+
+		topK := result
+		topK.TopK = &ingestor.TopKSummary{K: 2, Records: 10, ErrorBound: 2}
+		topK.Groups = []ingestor.Group{
+			{Values: []string{"bar", "200"}, Count: 6, CountError: 2},
+			{Values: []string{"bar", "500"}, Count: 3},
+		}
+
+		err = e.Export(context.Background(), topK)
+		require.NoError(t, err)
+
+		require.Len(t, srv.requests, 1)
+		metrics := srv.requests[0].GetResourceMetrics()[0].GetScopeMetrics()[0].GetMetrics()
+		require.Len(t, metrics, 2)
+		assert.Equal(t, exporter.CountErrorMetricName, metrics[1].GetName())
+
+		dataPoints := metrics[1].GetGauge().GetDataPoints()
+		require.Len(t, dataPoints, 2)
+		assert.Equal(t, int64(2), dataPoints[0].GetAsInt())
+		assert.Equal(t, int64(0), dataPoints[1].GetAsInt())
+	})
+
+	t.Run("exports severity breakdowns as a delta sum", func(t *testing.T) {
+		srv := &fakeMetricsServer{}
+		e, err := exporter.NewOTLPExporter(config.Config{
+			ExportOTLPEndpoint: startMetricsServer(t, srv),
+			ExportOTLPInsecure: true,
+			ExportOTLPTimeout:  time.Second,
+		})
+		require.NoError(t, err)
+		defer e.Close()
+
+		withSeverities := result
+		withSeverities.SeverityBreakdown = true
+		withSeverities.Groups = []ingestor.Group{{Values: []string{"bar", "200"}, Count: 5}}
+		withSeverities.Groups[0].Severities[ingestor.SeverityInfo] = 4
+		withSeverities.Groups[0].Severities[ingestor.SeverityError] = 1
+
+		err = e.Export(context.Background(), withSeverities)
+		require.NoError(t, err)
+
+		require.Len(t, srv.requests, 1)
+		metrics := srv.requests[0].GetResourceMetrics()[0].GetScopeMetrics()[0].GetMetrics()
+		require.Len(t, metrics, 2)
+		assert.Equal(t, exporter.SeverityMetricName, metrics[1].GetName())
+
+		dataPoints := metrics[1].GetSum().GetDataPoints()
+		require.Len(t, dataPoints, 2)
+		for i, expected := range []struct {
+			severity string
+			count    int64
+		}{{"INFO", 4}, {"ERROR", 1}} {
+			attributes := dataPoints[i].GetAttributes()
+			require.Len(t, attributes, 3)
+			assert.Equal(t, "severity", attributes[2].GetKey())
+			assert.Equal(t, expected.severity, attributes[2].GetValue().GetStringValue())
+			assert.Equal(t, expected.count, dataPoints[i].GetAsInt())
+		}
+	})
+
+	t.Run("retries retryable failures", func(t *testing.T) {
+		srv := &fakeMetricsServer{failures: 2}
+		e, err := exporter.NewOTLPExporter(config.Config{
+			ExportOTLPEndpoint:     startMetricsServer(t, srv),
+			ExportOTLPInsecure:     true,
+			ExportOTLPTimeout:      time.Second,
+			ExportOTLPMaxRetries:   2,
+			ExportOTLPRetryBackoff: time.Millisecond,
+		})
+		require.NoError(t, err)
+		defer e.Close()
+
+		err = e.Export(context.Background(), result)
+		require.NoError(t, err)
+		assert.Len(t, srv.requests, 1)
+	})
+
+	t.Run("gives up after max retries", func(t *testing.T) {
+		srv := &fakeMetricsServer{failures: 5}
+		e, err := exporter.NewOTLPExporter(config.Config{
+			ExportOTLPEndpoint:     startMetricsServer(t, srv),
+			ExportOTLPInsecure:     true,
+			ExportOTLPTimeout:      time.Second,
+			ExportOTLPMaxRetries:   1,
+			ExportOTLPRetryBackoff: time.Millisecond,
+		})
+		require.NoError(t, err)
+		defer e.Close()
+
+		err = e.Export(context.Background(), result)
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+		assert.Empty(t, srv.requests)
+	})
+
+	t.Run("rejects sliding windows", func(t *testing.T) {
+		_, err := exporter.NewOTLPExporter(config.Config{
+			ExportOTLPEndpoint: "localhost:4317",
+			AggregationWindow:  40 * time.Second,
+			WindowSlide:        10 * time.Second,
+		})
+		assert.Error(t, err, "Overlapping delta windows would be summed downstream")
+
+		// A slide of the whole window is a tumbling window.
+		e, err := exporter.NewOTLPExporter(config.Config{
+			ExportOTLPEndpoint: "localhost:4317",
+			AggregationWindow:  40 * time.Second,
+			WindowSlide:        40 * time.Second,
+		})
+		require.NoError(t, err)
+		assert.NoError(t, e.Close())
+	})
+}
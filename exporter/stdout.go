@@ -0,0 +1,91 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/miguelhrocha/otel-collector/ingestor"
+)
+
+// StdoutExporter prints flushed aggregation windows in a human readable format.
+type StdoutExporter struct {
+	w io.Writer
+}
+
+// NewStdoutExporter creates a new StdoutExporter writing to os.Stdout.
+func NewStdoutExporter() *StdoutExporter {
+	return &StdoutExporter{w: os.Stdout}
+}
+
+// Export prints the window, one group per line, after a header describing the window.
+func (e *StdoutExporter) Export(_ context.Context, result ingestor.WindowResult) error {
+	if len(result.Groups) == 0 {
+		_, err := io.WriteString(e.w, "aggregation window is empty\n")
+		return err
+	}
+
+	var b strings.Builder
+	// Corrections add late records to the counts of a previously exported window.
+	if result.Correction {
+		b.WriteString("correction of ")
+	}
+	fmt.Fprintf(&b, "aggregation window [%s, %s) by %s\n",
+		result.Start.Format("2006-01-02T15:04:05.000Z07:00"),
+		result.End.Format("2006-01-02T15:04:05.000Z07:00"),
+		strings.Join(result.AttributeKeys, ", "))
+	if t := result.TopK; t != nil {
+		fmt.Fprintf(&b, "top %d of %d records, counts overestimated by at most %d\n",
+			t.K, t.Records, t.ErrorBound)
+	}
+
+	for _, g := range result.Groups {
+		writeGroup(&b, result, g)
+	}
+	if result.Overflow.Records > 0 {
+		fmt.Fprintf(&b, "cardinality limit exceeded: %d records from ~%d keys counted as %s\n",
+			result.Overflow.Records, result.Overflow.Keys, ingestor.OverflowValue)
+	}
+	if result.LateRecords > 0 {
+		fmt.Fprintf(&b, "late records dropped: %d\n", result.LateRecords)
+	}
+	b.WriteString("-----\n")
+
+	_, err := io.WriteString(e.w, b.String())
+	return err
+}
+
+// writeGroup prints a group's attribute values, in the order of the window's attribute keys,
+// followed by its count and the results of the window's optional aggregations.
+func writeGroup(b *strings.Builder, result ingestor.WindowResult, g ingestor.Group) {
+	fmt.Fprintf(b, "%s - %d", strings.Join(g.Values, ", "), g.Count)
+	if g.CountError > 0 {
+		fmt.Fprintf(b, " (error <= %d)", g.CountError)
+	}
+	if v := g.Value; v.Count > 0 {
+		fmt.Fprintf(b, " (%s: count=%d sum=%g min=%g max=%g mean=%g)",
+			result.ValueKey, v.Count, v.Sum, v.Min, v.Max, v.Mean())
+	}
+	if h := g.Distribution; h != nil {
+		fmt.Fprintf(b, " (%s:", result.DistributionKey)
+		for _, q := range result.Quantiles {
+			fmt.Fprintf(b, " p%s=%g", quantileLabel(q), h.Quantile(q))
+		}
+		b.WriteString(")")
+	}
+	if result.SeverityBreakdown {
+		fmt.Fprintf(b, " (severity: %s)", g.Severities)
+	}
+	if g.Distinct > 0 {
+		fmt.Fprintf(b, " (%s: distinct=~%d)", result.DistinctKey, g.Distinct)
+	}
+	b.WriteString("\n")
+}
+
+// quantileLabel formats a quantile as a percentile, e.g. "99" for 0.99 or "99.9" for 0.999.
+func quantileLabel(q float64) string {
+	return strconv.FormatFloat(q*100, 'f', -1, 64)
+}
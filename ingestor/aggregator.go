@@ -1,7 +1,14 @@
 package ingestor
 
 import (
+	"cmp"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"slices"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/segmentio/fasthash/fnv1a"
 
@@ -11,10 +18,80 @@ import (
 // Aggregator handles aggregation of incoming logs.
 //
 // The Aggregator struct is responsible for aggregating log records based on specific keys.
-// It maintains counts of occurrences for each unique key, allowing for efficient aggregation
-// of log data before further processing or exporting.
+// It maintains counts of occurrences for each unique combination of attribute values per window,
+// allowing for efficient aggregation of log data before further processing or exporting.
 type Aggregator struct {
+	shards int
+
+	// maxKeys is the maximum number of distinct combinations per window, see config.MaxKeysPerWindow.
+	maxKeys         int64
+	maxKeysPerShard int
+
+	// histogramBuckets is the maximum number of buckets of the distribution histograms.
+	histogramBuckets int
+
+	// distinctPrecision is the precision of the distinct count sketches.
+	distinctPrecision uint8
+
+	// severityBreakdown enables counting records per severity range.
+	severityBreakdown bool
+
+	// topK is the number of groups reported in top-K mode, or 0 in exact mode.
+	// Top-K mode only tracks the most frequent combinations, see config.AggregationMode.
+	topK int
+
+	// topKCounters is the capacity of the Space-Saving summary of each shard in top-K mode.
+	topKCounters int
+
+	// eventTime assigns records to the tumbling window containing their timestamp,
+	// rather than to the current window until the next Flush. See config.WindowTime.
+	eventTime bool
+
+	// paneDuration is the duration of the windows in nanoseconds. With sliding windows,
+	// they are the panes the WindowManager merges into overlapping windows. See config.WindowSlide.
+	paneDuration int64
+
+	// allowedLateness is how far the watermark closing event-time windows trails the latest
+	// event time seen. Records for a window past the watermark are late, and handled
+	// according to latePolicy. See config.LateDataPolicy.
+	allowedLateness int64
+	latePolicy      string
+
+	// current is the processing-time window. Flush swaps it for a new one
+	// and seals the old one, see aggregatorShard.sealed.
+	current atomic.Pointer[window]
+
+	// mu guards the event-time windows, corrections and closed watermark. Records are
+	// added under the read lock, so a window is never flushed while a record is being
+	// added to it. It is never taken with processing-time windows.
+	mu sync.RWMutex
+
+	// windows holds the open event-time windows, keyed by their start in Unix nanoseconds.
+	windows map[int64]*window
+
+	// corrections holds the late records of already flushed event-time windows,
+	// keyed by the window start in Unix nanoseconds. See LatePolicyCorrect.
+	corrections map[int64]*window
+
+	// closed is the watermark at the last flush, records of windows ending before it are late.
+	closed int64
+
+	// maxEventTime is the latest event time seen, in Unix nanoseconds.
+	maxEventTime atomic.Int64
+
+	// late is the number of late records counted since the last flush, see LatePolicyCount.
+	late atomic.Int64
+}
+
+// window is the aggregated state of a single window, sharded to reduce lock contention.
+type window struct {
 	shards []aggregatorShard
+
+	// topK is the number of groups reported in top-K mode, or 0 in exact mode.
+	topK int
+
+	// keys is the number of distinct combinations currently held across all shards.
+	keys atomic.Int64
 }
 
 type aggregatorShard struct {
@@ -22,29 +99,503 @@ type aggregatorShard struct {
 	// and sync.RWMutex would add unnecessary overhead.
 	mu sync.Mutex
 
+	// sealed is set once the window is flushed. Records racing with the flush
+	// find the shard sealed and go to the next window instead.
+	sealed bool
+
 	// Chose a map + mutex intead of sync.Map because
 	// the access pattern is mostly writes.
-	data map[string]int64
+	//
+	// Keyed by the encoded attribute values, see encodeValues.
+	// It is nil in top-K mode.
+	data map[string]*Group
+
+	// summary tracks the most frequent combinations in top-K mode instead of data.
+	summary *spaceSaving
+
+	// overflow counts the records whose combination exceeded the cardinality limit.
+	overflow *Group
+
+	// dropped estimates the number of distinct combinations counted into overflow.
+	// It is allocated on the first overflowing record.
+	dropped *hyperLogLog
+}
+
+const (
+	// AggregationModeExact counts every combination of attribute values.
+	AggregationModeExact = "exact"
+
+	// AggregationModeTopK only tracks the most frequent combinations of attribute values.
+	AggregationModeTopK = "topk"
+)
+
+const (
+	// WindowTimeProcessing assigns records to windows by their arrival time.
+	WindowTimeProcessing = "processing"
+
+	// WindowTimeEvent assigns records to windows by their timestamp,
+	// falling back to their observed timestamp.
+	WindowTimeEvent = "event"
+)
+
+const (
+	// LatePolicyDrop discards late records.
+	LatePolicyDrop = "drop"
+
+	// LatePolicyCount discards late records, but counts them into the next flushed window,
+	// see WindowSnapshot.Late.
+	LatePolicyCount = "count"
+
+	// LatePolicyCorrect aggregates late records into a correction of their already
+	// flushed window, emitted on the next flush. See WindowSnapshot.Correction.
+	LatePolicyCorrect = "correct"
+)
+
+// OverflowValue is the attribute value of the group that records are counted
+// into once the cardinality limit of a window is reached.
+const OverflowValue = "__overflow__"
+
+// overflowPrecision is the precision of the sketch estimating the dropped combinations
+// of a shard, for a standard error of about 1.6% using 4KB per shard.
+const overflowPrecision = 12
+
+const (
+	minDistinctPrecision     = 4
+	maxDistinctPrecision     = 18
+	defaultDistinctPrecision = 14
+)
+
+// Group is the aggregated state of a single combination of attribute values.
+type Group struct {
+	// Values holds one value per configured attribute key, in the same order.
+	Values []string
+
+	// Count is the number of records aggregated into the group.
+	Count int64
+
+	// CountError is the maximum overestimation of Count in top-K mode,
+	// so the group has at least Count - CountError records. It is 0 in exact mode.
+	CountError int64
+
+	// Value aggregates the numeric values of the records in the group,
+	// see config.ValueAttributeKey.
+	Value ValueStats
+
+	// Distribution is the histogram of the distribution values of the records
+	// in the group, or nil if none had one. See config.DistributionAttributeKey.
+	Distribution *ExponentialHistogram
+
+	// Severities breaks Count down by severity range when enabled,
+	// see config.SeverityBreakdown.
+	Severities SeverityCounts
+
+	// Distinct is the estimated number of distinct values of the records in the group,
+	// see config.DistinctAttributeKey. It is set when the group is snapshotted.
+	Distinct int64
+
+	// distinct is the sketch Distinct is estimated from, or nil if no record had a
+	// distinct value, kept so that groups can be merged.
+	distinct *hyperLogLog
+
+	// sampledOut is the estimated number of records dropped by sampling,
+	// added to Count when the group is snapshotted. See Record.Weight.
+	sampledOut float64
+}
+
+// ValueStats aggregates numeric values.
+type ValueStats struct {
+	// Count is the number of values aggregated, which can be lower than the
+	// number of records if some records have no value.
+	Count int64
+
+	Sum float64
+	Min float64
+	Max float64
+}
+
+// Mean returns the arithmetic mean of the values, or 0 if there are none.
+func (s ValueStats) Mean() float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Sum / float64(s.Count)
+}
+
+func (s *ValueStats) add(v float64) {
+	if s.Count == 0 || v < s.Min {
+		s.Min = v
+	}
+	if s.Count == 0 || v > s.Max {
+		s.Max = v
+	}
+	s.Sum += v
+	s.Count++
+}
+
+func (s *ValueStats) merge(other ValueStats) {
+	if other.Count == 0 {
+		return
+	}
+	if s.Count == 0 || other.Min < s.Min {
+		s.Min = other.Min
+	}
+	if s.Count == 0 || other.Max > s.Max {
+		s.Max = other.Max
+	}
+	s.Sum += other.Sum
+	s.Count += other.Count
+}
+
+// add aggregates a record into the group.
+func (g *Group) add(r Record) {
+	g.Count++
+	g.sampledOut += r.weight() - 1
+
+	if r.HasValue {
+		g.Value.add(r.Value)
+	}
+	if r.HasDistribution {
+		g.Distribution.add(r.Distribution)
+	}
+	if r.HasDistinct {
+		g.distinct.add(fnv1a.HashString64(r.Distinct))
+	}
+}
+
+// merge adds the aggregated state of another group with the same values to g.
+func (g *Group) merge(other Group) {
+	g.Count += other.Count
+	g.CountError += other.CountError
+	g.Severities.merge(other.Severities)
+	g.sampledOut += other.sampledOut
+	g.Value.merge(other.Value)
+
+	if other.Distribution != nil {
+		if g.Distribution == nil {
+			// Never share the histogram with the merged group.
+			g.Distribution = other.Distribution.clone()
+		} else {
+			g.Distribution.merge(other.Distribution)
+		}
+	}
+
+	if other.distinct != nil {
+		if g.distinct == nil {
+			g.distinct = other.distinct.clone()
+		} else {
+			g.distinct.merge(other.distinct)
+		}
+	}
+}
+
+// settle sets the fields derived when the group is snapshotted: Distinct from the
+// group's sketch, if any, and Count scaled up by the records dropped by sampling.
+func (g *Group) settle() {
+	if g.distinct != nil {
+		g.Distinct = int64(g.distinct.estimate())
+	}
+	if g.sampledOut > 0 {
+		g.Count += int64(math.Round(g.sampledOut))
+		g.sampledOut = 0
+	}
+}
+
+// Overflow describes the records of a window that exceeded its cardinality limit.
+type Overflow struct {
+	// Records is the number of records counted into the overflow group.
+	Records int64
+
+	// Keys is the estimated number of distinct attribute value combinations
+	// that were counted into the overflow group instead of their own group.
+	Keys int64
+}
+
+// Snapshot is the aggregated state of an Aggregator at the time it was flushed.
+type Snapshot struct {
+	// Groups holds the aggregated groups, sorted by their values.
+	// The overflow group, if any, is always last.
+	Groups []Group
+
+	// Overflow describes the records counted into the overflow group.
+	Overflow Overflow
+
+	// TopK describes the top-K summary the groups were taken from,
+	// or is nil in exact mode. Groups are then sorted by decreasing count.
+	TopK *TopKSummary
+
+	// dropped estimates the distinct combinations counted into the overflow group,
+	// kept so that snapshots can be merged.
+	dropped *hyperLogLog
+}
+
+// WindowSnapshot is the aggregated state of a flushed event-time window,
+// or of a single pane of a sliding window.
+type WindowSnapshot struct {
+	Snapshot
+
+	// Start is the inclusive start of the window.
+	Start time.Time
+
+	// End is the exclusive end of the window.
+	End time.Time
+
+	// Correction reports whether the snapshot holds the late records of a window
+	// flushed before, to be added to its previous counts.
+	Correction bool
+
+	// Late is the number of late records discarded since the previous flush.
+	// It is only set with LatePolicyCount, on the first window of a flush.
+	Late int64
+}
+
+// Count returns the count of the group with the given values,
+// or 0 if there is no such group.
+func (s Snapshot) Count(values ...string) int64 {
+	for _, g := range s.Groups {
+		if slices.Equal(g.Values, values) {
+			return g.Count
+		}
+	}
+	return 0
 }
 
 // NewAggregator creates a new Aggregator instance with the amount
 // of shards specified in the config's Shards field.
-func NewAggregator(cfg config.Config) *Aggregator {
-	s := make([]aggregatorShard, cfg.Shards)
+//
+// It returns an error if one of the config's aggregation settings is not supported or out of range.
+func NewAggregator(cfg config.Config) (*Aggregator, error) {
+	a := &Aggregator{
+		shards:            cfg.Shards,
+		maxKeys:           int64(cfg.MaxKeysPerWindow),
+		maxKeysPerShard:   cfg.MaxKeysPerShard,
+		histogramBuckets:  cfg.DistributionMaxBuckets,
+		distinctPrecision: defaultDistinctPrecision,
+		severityBreakdown: cfg.SeverityBreakdown,
+		paneDuration:      int64(cfg.AggregationWindow),
+		allowedLateness:   int64(cfg.AllowedLateness),
+		latePolicy:        cfg.LateDataPolicy,
+		windows:           make(map[int64]*window),
+		corrections:       make(map[int64]*window),
+		closed:            math.MinInt64,
+	}
+	a.maxEventTime.Store(math.MinInt64)
+
+	// Sliding windows are built from panes of the slide interval, see WindowManager.
+	if cfg.WindowSlide > 0 {
+		if cfg.WindowSlide > cfg.AggregationWindow || cfg.AggregationWindow%cfg.WindowSlide != 0 {
+			return nil, fmt.Errorf("aggregation window %s is not a multiple of the window slide %s", cfg.AggregationWindow, cfg.WindowSlide)
+		}
+		a.paneDuration = int64(cfg.WindowSlide)
+	}
+
+	switch cfg.WindowTime {
+	case WindowTimeProcessing, "":
+	case WindowTimeEvent:
+		if cfg.AggregationWindow <= 0 {
+			return nil, fmt.Errorf("event-time windows require a positive aggregation window")
+		}
+		a.eventTime = true
+	default:
+		return nil, fmt.Errorf("unknown window time %q", cfg.WindowTime)
+	}
+
+	switch cfg.LateDataPolicy {
+	case LatePolicyDrop, LatePolicyCount, LatePolicyCorrect:
+	case "":
+		a.latePolicy = LatePolicyDrop
+	default:
+		return nil, fmt.Errorf("unknown late data policy %q", cfg.LateDataPolicy)
+	}
+
+	if p := cfg.DistinctPrecision; p != 0 {
+		if p < minDistinctPrecision || p > maxDistinctPrecision {
+			return nil, fmt.Errorf("distinct precision %d is not between %d and %d", p, minDistinctPrecision, maxDistinctPrecision)
+		}
+		a.distinctPrecision = uint8(p)
+	}
+
+	for _, q := range cfg.DistributionQuantiles {
+		if !(q >= 0 && q <= 1) {
+			return nil, fmt.Errorf("distribution quantile %g is not between 0 and 1", q)
+		}
+	}
+
+	switch cfg.AggregationMode {
+	case AggregationModeExact, "":
+	case AggregationModeTopK:
+		if cfg.TopK <= 0 {
+			return nil, fmt.Errorf("top-K mode requires a positive K, got %d", cfg.TopK)
+		}
+		counters := cfg.TopKCounters
+		if counters == 0 {
+			counters = 10 * cfg.TopK
+		}
+		if counters < cfg.TopK {
+			return nil, fmt.Errorf("top-K counters %d are fewer than K %d", counters, cfg.TopK)
+		}
+		a.topK = cfg.TopK
+		a.topKCounters = (counters + a.shards - 1) / a.shards
+	default:
+		return nil, fmt.Errorf("unknown aggregation mode %q", cfg.AggregationMode)
+	}
+
+	a.current.Store(a.newWindow())
+
+	return a, nil
+}
+
+func (a *Aggregator) newWindow() *window {
+	s := make([]aggregatorShard, a.shards)
 
 	for i := range s {
-		s[i] = aggregatorShard{
-			data: make(map[string]int64),
+		if a.topK > 0 {
+			s[i] = aggregatorShard{summary: newSpaceSaving(a.topKCounters)}
+		} else {
+			s[i] = aggregatorShard{data: make(map[string]*Group)}
 		}
 	}
 
-	return &Aggregator{
-		shards: s,
+	return &window{shards: s, topK: a.topK}
+}
+
+// EventTime reports whether records are assigned to windows by event time.
+func (a *Aggregator) EventTime() bool {
+	return a.eventTime
+}
+
+// PaneDuration returns the duration of the Aggregator's windows,
+// the slide interval of sliding windows or the aggregation window otherwise.
+func (a *Aggregator) PaneDuration() time.Duration {
+	return time.Duration(a.paneDuration)
+}
+
+// Add aggregates a record into its window, reporting whether it was late.
+//
+// Besides counting the record, its numeric value, distribution value and
+// distinct value are aggregated if it has them.
+//
+// With processing-time windows, the record goes to the current window. With event-time windows,
+// the record is assigned to the window containing its timestamp. Late records
+// are dropped, counted or aggregated into a correction depending on the late data policy.
+func (a *Aggregator) Add(r Record) bool {
+	if !a.eventTime {
+		a.incCurrent(r)
+		return false
 	}
+
+	ts := eventTime(r)
+	a.observe(ts)
+	start := ts - mod(ts, a.paneDuration)
+
+	a.mu.RLock()
+	w, late := a.window(start)
+	if w != nil || (late && a.latePolicy != LatePolicyCorrect) {
+		if w != nil {
+			a.inc(w, r)
+		} else if a.latePolicy == LatePolicyCount {
+			a.late.Add(1)
+		}
+		a.mu.RUnlock()
+		return late
+	}
+	a.mu.RUnlock()
+
+	// The window does not exist yet, create it under the write lock.
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// The window may have been created or closed in the meantime.
+	w, late = a.window(start)
+	if w == nil {
+		if late && a.latePolicy != LatePolicyCorrect {
+			if a.latePolicy == LatePolicyCount {
+				a.late.Add(1)
+			}
+			return true
+		}
+
+		w = a.newWindow()
+		if late {
+			a.corrections[start] = w
+		} else {
+			a.windows[start] = w
+		}
+	}
+	a.inc(w, r)
+	return late
+}
+
+// window returns the event-time window or correction starting at start,
+// if it exists, and whether records of the window are late.
+//
+// The lock must be held.
+func (a *Aggregator) window(start int64) (*window, bool) {
+	if start+a.paneDuration > a.closed {
+		return a.windows[start], false
+	}
+	return a.corrections[start], true
 }
 
-// Inc increments the counter for the given key.
-func (a *Aggregator) Inc(key string) {
+// observe advances the latest event time seen.
+//
+// Event times are capped at the wall clock plus the allowed lateness, so a single
+// record from a clock far in the future cannot make every later record late.
+func (a *Aggregator) observe(ts int64) {
+	ts = min(ts, time.Now().UnixNano()+a.allowedLateness)
+	for {
+		maxTs := a.maxEventTime.Load()
+		if ts <= maxTs || a.maxEventTime.CompareAndSwap(maxTs, ts) {
+			return
+		}
+	}
+}
+
+// Watermark returns the event time up to which windows are complete,
+// i.e. the latest event time seen minus the allowed lateness.
+// It never passes the wall clock.
+//
+// Before any record with event time is added, it is the zero time.
+func (a *Aggregator) Watermark() time.Time {
+	watermark := a.watermark()
+	if watermark == math.MinInt64 {
+		return time.Time{}
+	}
+	return time.Unix(0, watermark)
+}
+
+func (a *Aggregator) watermark() int64 {
+	maxTs := a.maxEventTime.Load()
+	if maxTs == math.MinInt64 {
+		return math.MinInt64
+	}
+	return maxTs - a.allowedLateness
+}
+
+// Inc increments the counter for the given combination of attribute values
+// in the current processing-time window.
+//
+// If the combination is new and the cardinality limit has been reached,
+// the overflow group is incremented instead.
+func (a *Aggregator) Inc(values []string) {
+	a.incCurrent(Record{AttrValues: values})
+}
+
+// incCurrent aggregates a record into the current processing-time window.
+func (a *Aggregator) incCurrent(r Record) {
+	// The window loaded may be flushed before the record reaches its shard,
+	// then the record goes to the window that replaced it.
+	for !a.inc(a.current.Load(), r) {
+	}
+}
+
+// inc aggregates a record into the group of its attribute values in a window,
+// reporting false if the window was flushed in the meantime.
+//
+// Event-time windows must be accessed under the read lock.
+func (a *Aggregator) inc(w *window, r Record) bool {
+	values := r.AttrValues
+	key := encodeValues(values)
+
 	// Use FNV-1a hash to determine the shard for the given key.
 	//
 	// FNV-1a is a fast, non-cryptographic hash function that
@@ -53,31 +604,335 @@ func (a *Aggregator) Inc(key string) {
 	//
 	// This design gives us a lock granularity of 1/shards,
 	// which improves concurrency and throughput in write-heavy workloads.
-	hash := fnv1a.HashBytes64([]byte(key))
-	shardKey := hash % uint64(len(a.shards))
+	hash := fnv1a.HashString64(key)
+	shardKey := hash % uint64(len(w.shards))
 
-	shard := &a.shards[shardKey]
+	shard := &w.shards[shardKey]
 	shard.mu.Lock()
-	shard.data[key]++
-	shard.mu.Unlock()
+	defer shard.mu.Unlock()
+
+	if shard.sealed {
+		return false
+	}
+
+	if shard.summary != nil {
+		c := shard.summary.counter(key, values, r.weight())
+		a.aggregate(&c.group, r)
+		shard.summary.fix(c)
+		return true
+	}
+
+	g, ok := shard.data[key]
+	if !ok {
+		if a.reserveKey(w, shard) {
+			g = &Group{Values: slices.Clone(values)}
+			shard.data[key] = g
+		} else {
+			g = shard.overflowGroup(len(values), hash)
+		}
+	}
+
+	a.aggregate(g, r)
+	return true
 }
 
-// Flush returns a snapshot of the current aggregated data
-// and resets the internal state of the aggregator.
+// aggregate adds a record to a group, allocating the group's sketches as needed.
+func (a *Aggregator) aggregate(g *Group, r Record) {
+	if r.HasDistribution && g.Distribution == nil {
+		g.Distribution = newExponentialHistogram(a.histogramBuckets)
+	}
+	if r.HasDistinct && g.distinct == nil {
+		g.distinct = newHyperLogLog(a.distinctPrecision)
+	}
+	if a.severityBreakdown {
+		g.Severities[SeverityRangeOf(r.Severity)]++
+	}
+	g.add(r)
+}
+
+// reserveKey reports whether a new combination fits within the cardinality limits,
+// reserving room for it in the window if so.
+//
+// The shard lock must be held.
+func (a *Aggregator) reserveKey(w *window, shard *aggregatorShard) bool {
+	if a.maxKeysPerShard > 0 && len(shard.data) >= a.maxKeysPerShard {
+		return false
+	}
+
+	if w.keys.Add(1) > a.maxKeys && a.maxKeys > 0 {
+		w.keys.Add(-1)
+		return false
+	}
+	return true
+}
+
+// overflowGroup returns the group counting the records whose combination
+// exceeded the cardinality limit, recording the combination's hash.
+//
+// The shard lock must be held.
+func (sh *aggregatorShard) overflowGroup(dimensions int, hash uint64) *Group {
+	if sh.overflow == nil {
+		values := make([]string, dimensions)
+		for i := range values {
+			values[i] = OverflowValue
+		}
+		sh.overflow = &Group{Values: values}
+	}
+
+	if sh.dropped == nil {
+		sh.dropped = newHyperLogLog(overflowPrecision)
+	}
+	sh.dropped.add(hash)
+
+	return sh.overflow
+}
+
+// Flush returns a snapshot of the current processing-time window
+// and starts a new one.
 //
 // This is used to periodically flush the aggregated data.
-func (a *Aggregator) Flush() map[string]int64 {
-	result := make(map[string]int64)
+func (a *Aggregator) Flush() Snapshot {
+	return a.current.Swap(a.newWindow()).snapshot()
+}
 
-	for i := range a.shards {
-		sh := &a.shards[i]
-		sh.mu.Lock()
-		for k, v := range sh.data {
-			result[k] += v
+// FlushClosed returns the snapshots of the event-time windows closed by the watermark,
+// sorted by start, followed by the corrections of previously flushed windows.
+//
+// If all is set, every open window is flushed regardless of the watermark, e.g. on shutdown.
+func (a *Aggregator) FlushClosed(all bool) []WindowSnapshot {
+	type pending struct {
+		start      int64
+		w          *window
+		correction bool
+	}
+
+	var closed []pending
+
+	a.mu.Lock()
+	watermark := a.watermark()
+	for start, w := range a.windows {
+		if end := start + a.paneDuration; all || end <= watermark {
+			closed = append(closed, pending{start: start, w: w})
+			delete(a.windows, start)
+			watermark = max(watermark, end)
 		}
-		sh.data = make(map[string]int64)
+	}
+	// Windows ending before the watermark are complete, later records for them are late.
+	a.closed = max(a.closed, watermark)
+
+	for start, w := range a.corrections {
+		closed = append(closed, pending{start: start, w: w, correction: true})
+	}
+	a.corrections = make(map[int64]*window)
+	a.mu.Unlock()
+
+	// Corrections go after the closed windows.
+	slices.SortFunc(closed, func(x, y pending) int {
+		if x.correction != y.correction {
+			if x.correction {
+				return 1
+			}
+			return -1
+		}
+		return cmp.Compare(x.start, y.start)
+	})
+
+	snapshots := make([]WindowSnapshot, 0, len(closed))
+	for _, p := range closed {
+		snapshots = append(snapshots, WindowSnapshot{
+			Snapshot:   p.w.snapshot(),
+			Start:      time.Unix(0, p.start),
+			End:        time.Unix(0, p.start+a.paneDuration),
+			Correction: p.correction,
+		})
+	}
+
+	if len(snapshots) > 0 && !snapshots[0].Correction {
+		snapshots[0].Late = a.late.Swap(0)
+	}
+
+	return snapshots
+}
+
+// snapshot seals a window and returns its aggregated state.
+func (w *window) snapshot() Snapshot {
+	for i := range w.shards {
+		sh := &w.shards[i]
+		sh.mu.Lock()
+		sh.sealed = true
 		sh.mu.Unlock()
 	}
 
-	return result
+	if w.topK > 0 {
+		return w.topKSnapshot()
+	}
+
+	var (
+		groups   []Group
+		overflow *Group
+		dropped  *hyperLogLog
+	)
+
+	for i := range w.shards {
+		sh := &w.shards[i]
+		data, shardOverflow, shardDropped := sh.data, sh.overflow, sh.dropped
+
+		// Every key maps to exactly one shard, so groups never need merging across shards.
+		for _, g := range data {
+			groups = append(groups, *g)
+		}
+
+		// The overflow groups of all shards are merged into a single group.
+		if shardOverflow != nil {
+			if overflow == nil {
+				overflow, dropped = shardOverflow, shardDropped
+			} else {
+				overflow.merge(*shardOverflow)
+				dropped.merge(shardDropped)
+			}
+		}
+	}
+
+	return newSnapshot(groups, overflow, dropped, nil)
+}
+
+// topKSnapshot returns the aggregated state of a window in top-K mode.
+//
+// Every key maps to exactly one shard, so the window's top groups are
+// the top groups of the shards' summaries combined.
+func (w *window) topKSnapshot() Snapshot {
+	var groups []Group
+	summary := &TopKSummary{K: w.topK}
+
+	for i := range w.shards {
+		ss := w.shards[i].summary
+		groups = append(groups, ss.groups()...)
+		summary.Records += int64(math.Round(ss.records))
+		summary.ErrorBound = max(summary.ErrorBound, ss.errorBound())
+	}
+
+	return newSnapshot(groups, nil, nil, summary)
+}
+
+// mergeSnapshots merges the snapshots of consecutive panes into the snapshot of a single window.
+func mergeSnapshots(snapshots ...Snapshot) Snapshot {
+	if len(snapshots) == 1 {
+		return snapshots[0]
+	}
+
+	var (
+		merged   = make(map[string]*Group)
+		overflow *Group
+		dropped  *hyperLogLog
+		topK     *TopKSummary
+	)
+
+	for _, s := range snapshots {
+		if s.TopK != nil {
+			if topK == nil {
+				topK = &TopKSummary{K: s.TopK.K}
+			}
+			topK.merge(s.TopK)
+		}
+
+		groups := s.Groups
+		if s.Overflow.Records > 0 {
+			// The overflow group is always last.
+			groups = groups[:len(groups)-1]
+
+			if overflow == nil {
+				overflow = &Group{Values: s.Groups[len(s.Groups)-1].Values}
+				dropped = newHyperLogLog(overflowPrecision)
+			}
+			overflow.merge(s.Groups[len(s.Groups)-1])
+			dropped.merge(s.dropped)
+		}
+
+		for _, g := range groups {
+			key := encodeValues(g.Values)
+			m, ok := merged[key]
+			if !ok {
+				m = &Group{Values: g.Values}
+				merged[key] = m
+			}
+			m.merge(g)
+		}
+	}
+
+	groups := make([]Group, 0, len(merged))
+	for _, g := range merged {
+		groups = append(groups, *g)
+	}
+	return newSnapshot(groups, overflow, dropped, topK)
+}
+
+// newSnapshot settles and sorts the groups, and appends the overflow group, if any, last.
+//
+// Groups are sorted by their values, or by decreasing count in top-K mode.
+func newSnapshot(groups []Group, overflow *Group, dropped *hyperLogLog, topK *TopKSummary) Snapshot {
+	for i := range groups {
+		groups[i].settle()
+	}
+	if overflow != nil {
+		overflow.settle()
+	}
+
+	slices.SortFunc(groups, func(a, b Group) int {
+		if topK != nil && a.Count != b.Count {
+			return cmp.Compare(b.Count, a.Count)
+		}
+		return slices.Compare(a.Values, b.Values)
+	})
+
+	snapshot := Snapshot{Groups: groups, TopK: topK}
+	if overflow != nil {
+		snapshot.Groups = append(snapshot.Groups, *overflow)
+		snapshot.Overflow = Overflow{
+			Records: overflow.Count,
+			Keys:    int64(dropped.estimate()),
+		}
+		snapshot.dropped = dropped
+	}
+
+	return snapshot
+}
+
+// eventTime returns the timestamp of a record in Unix nanoseconds,
+// falling back to its observed timestamp and then to the current time.
+func eventTime(r Record) int64 {
+	switch {
+	case r.TimeUnix != 0:
+		return int64(r.TimeUnix)
+	case r.ObsUnix != 0:
+		return int64(r.ObsUnix)
+	default:
+		return time.Now().UnixNano()
+	}
+}
+
+// mod returns the non-negative remainder of a divided by b.
+func mod(a, b int64) int64 {
+	m := a % b
+	if m < 0 {
+		m += b
+	}
+	return m
+}
+
+// encodeValues encodes a combination of attribute values into a map key.
+//
+// Each value is prefixed with its length so that different combinations
+// can never encode to the same key, regardless of the characters they contain.
+func encodeValues(values []string) string {
+	n := 0
+	for _, v := range values {
+		n += len(v) + binary.MaxVarintLen64
+	}
+
+	b := make([]byte, 0, n)
+	for _, v := range values {
+		b = binary.AppendUvarint(b, uint64(len(v)))
+		b = append(b, v...)
+	}
+	return string(b)
 }
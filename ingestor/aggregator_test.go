@@ -1,25 +1,469 @@
 package ingestor_test
 
 import (
+	"fmt"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/miguelhrocha/otel-collector/config"
 	"github.com/miguelhrocha/otel-collector/ingestor"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestAggregator(t *testing.T) {
 
-	aggregator := ingestor.NewAggregator(config.Config{
+	aggregator, err := ingestor.NewAggregator(config.Config{
 		Shards: 10,
 	})
+	require.NoError(t, err)
 
-	aggregator.Inc("foo")
-	aggregator.Inc("bar")
-	aggregator.Inc("foo")
+	aggregator.Inc([]string{"foo"})
+	aggregator.Inc([]string{"bar"})
+	aggregator.Inc([]string{"foo"})
 
 	snapshot := aggregator.Flush()
 
-	assert.Equal(t, int64(2), snapshot["foo"], "Aggregated count for 'foo' does not match")
-	assert.Equal(t, int64(1), snapshot["bar"], "Aggregated count for 'bar' does not match")
+	assert.Equal(t, int64(2), snapshot.Count("foo"), "Aggregated count for 'foo' does not match")
+	assert.Equal(t, int64(1), snapshot.Count("bar"), "Aggregated count for 'bar' does not match")
+}
+
+func TestAggregatorConcurrentFlush(t *testing.T) {
+	aggregator, err := ingestor.NewAggregator(config.Config{Shards: 4})
+	require.NoError(t, err)
+
+	const writers, records = 8, 10_000
+
+	var wg sync.WaitGroup
+	for w := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range records {
+				aggregator.Inc([]string{fmt.Sprintf("key-%d-%d", w, i%16)})
+			}
+		}()
+	}
+
+	// Records racing with a flush land in either window, never in none.
+	var total int64
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	for flushing := true; flushing; {
+		select {
+		case <-done:
+			flushing = false
+		default:
+		}
+		for _, g := range aggregator.Flush().Groups {
+			total += g.Count
+		}
+	}
+
+	assert.Equal(t, int64(writers*records), total)
+}
+
+func TestAggregatorCompositeKeys(t *testing.T) {
+	aggregator, err := ingestor.NewAggregator(config.Config{
+		Shards: 10,
+	})
+	require.NoError(t, err)
+
+	aggregator.Inc([]string{"checkout", "/pay", "200"})
+	aggregator.Inc([]string{"checkout", "/pay", "500"})
+	aggregator.Inc([]string{"checkout", "/pay", "200"})
+
+	// Values must not be concatenated into a single key.
+	aggregator.Inc([]string{"checkout/pay", "", "200"})
+
+	snapshot := aggregator.Flush()
+
+	assert.Len(t, snapshot.Groups, 3)
+	assert.Equal(t, int64(2), snapshot.Count("checkout", "/pay", "200"))
+	assert.Equal(t, int64(1), snapshot.Count("checkout", "/pay", "500"))
+	assert.Equal(t, int64(1), snapshot.Count("checkout/pay", "", "200"))
+
+	assert.Empty(t, aggregator.Flush().Groups, "Flush should reset the aggregator")
+}
+
+func TestAggregatorCardinalityLimit(t *testing.T) {
+	t.Run("counts new keys into the overflow group once the window limit is reached", func(t *testing.T) {
+		aggregator, err := ingestor.NewAggregator(config.Config{
+			Shards:           10,
+			MaxKeysPerWindow: 10,
+		})
+		require.NoError(t, err)
+
+		for i := range 1000 {
+			aggregator.Inc([]string{fmt.Sprintf("request-%d", i), "200"})
+		}
+		// Keys admitted before the limit keep being counted in their own group.
+		aggregator.Inc([]string{"request-0", "200"})
+
+		snapshot := aggregator.Flush()
+
+		assert.Len(t, snapshot.Groups, 11)
+		assert.Equal(t, int64(2), snapshot.Count("request-0", "200"))
+		assert.Equal(t, int64(990), snapshot.Count(ingestor.OverflowValue, ingestor.OverflowValue))
+		assert.Equal(t, int64(990), snapshot.Overflow.Records)
+		assert.InDelta(t, 990, snapshot.Overflow.Keys, 990*0.05)
+
+		// The limit applies per window.
+		aggregator.Inc([]string{"request-1000", "200"})
+		snapshot = aggregator.Flush()
+		assert.Equal(t, int64(1), snapshot.Count("request-1000", "200"))
+		assert.Zero(t, snapshot.Overflow.Records)
+	})
+
+	t.Run("limits keys per shard", func(t *testing.T) {
+		aggregator, err := ingestor.NewAggregator(config.Config{
+			Shards:          1,
+			MaxKeysPerShard: 2,
+		})
+		require.NoError(t, err)
+
+		aggregator.Inc([]string{"a"})
+		aggregator.Inc([]string{"b"})
+		aggregator.Inc([]string{"c"})
+		aggregator.Inc([]string{"c"})
+
+		snapshot := aggregator.Flush()
+
+		assert.Equal(t, int64(1), snapshot.Count("a"))
+		assert.Equal(t, int64(1), snapshot.Count("b"))
+		assert.Equal(t, int64(2), snapshot.Overflow.Records)
+		assert.Equal(t, int64(1), snapshot.Overflow.Keys)
+	})
+}
+
+func TestAggregatorEventTime(t *testing.T) {
+	base := time.Unix(1000, 0)
+	at := func(d time.Duration) uint64 {
+		return uint64(base.Add(d).UnixNano())
+	}
+
+	for _, policy := range []string{ingestor.LatePolicyDrop, ingestor.LatePolicyCount, ingestor.LatePolicyCorrect} {
+		t.Run(policy, func(t *testing.T) {
+			aggregator, err := ingestor.NewAggregator(config.Config{
+				Shards:            4,
+				AggregationWindow: 10 * time.Second,
+				WindowTime:        ingestor.WindowTimeEvent,
+				AllowedLateness:   5 * time.Second,
+				LateDataPolicy:    policy,
+			})
+			require.NoError(t, err)
+
+			assert.False(t, aggregator.Add(ingestor.Record{AttrValues: []string{"a"}, TimeUnix: at(time.Second)}))
+			assert.False(t, aggregator.Add(ingestor.Record{AttrValues: []string{"a"}, ObsUnix: at(3 * time.Second)}))
+			assert.False(t, aggregator.Add(ingestor.Record{AttrValues: []string{"b"}, TimeUnix: at(12 * time.Second)}))
+
+			// The watermark has not passed the end of the first window yet.
+			assert.Equal(t, base.Add(7*time.Second), aggregator.Watermark())
+			assert.Empty(t, aggregator.FlushClosed(false))
+
+			// Records within the allowed lateness still land in their window.
+			assert.False(t, aggregator.Add(ingestor.Record{AttrValues: []string{"a"}, TimeUnix: at(9 * time.Second)}))
+			assert.False(t, aggregator.Add(ingestor.Record{AttrValues: []string{"c"}, TimeUnix: at(16 * time.Second)}))
+
+			windows := aggregator.FlushClosed(false)
+			require.Len(t, windows, 1)
+			assert.Equal(t, base, windows[0].Start)
+			assert.Equal(t, base.Add(10*time.Second), windows[0].End)
+			assert.Equal(t, int64(3), windows[0].Count("a"))
+			assert.False(t, windows[0].Correction)
+
+			assert.True(t, aggregator.Add(ingestor.Record{AttrValues: []string{"a"}, TimeUnix: at(2 * time.Second)}), "Expected the record to be late")
+
+			windows = aggregator.FlushClosed(true)
+			require.NotEmpty(t, windows)
+			assert.Equal(t, base.Add(10*time.Second), windows[0].Start)
+			assert.Equal(t, int64(1), windows[0].Count("b"))
+			assert.Equal(t, int64(1), windows[0].Count("c"))
+
+			switch policy {
+			case ingestor.LatePolicyDrop:
+				assert.Len(t, windows, 1)
+				assert.Zero(t, windows[0].Late)
+			case ingestor.LatePolicyCount:
+				assert.Len(t, windows, 1)
+				assert.Equal(t, int64(1), windows[0].Late)
+			case ingestor.LatePolicyCorrect:
+				require.Len(t, windows, 2)
+				assert.True(t, windows[1].Correction)
+				assert.Equal(t, base, windows[1].Start)
+				assert.Equal(t, int64(1), windows[1].Count("a"))
+			}
+		})
+	}
+
+	t.Run("ignores event times in the future", func(t *testing.T) {
+		aggregator, err := ingestor.NewAggregator(config.Config{
+			Shards:            4,
+			AggregationWindow: 10 * time.Second,
+			WindowTime:        ingestor.WindowTimeEvent,
+			AllowedLateness:   5 * time.Second,
+		})
+		require.NoError(t, err)
+
+		now := time.Now()
+		assert.False(t, aggregator.Add(ingestor.Record{AttrValues: []string{"a"}, TimeUnix: uint64(now.Add(24 * time.Hour).UnixNano())}))
+		assert.False(t, aggregator.Watermark().After(time.Now()), "Expected the watermark not to pass the wall clock")
+
+		assert.False(t, aggregator.Add(ingestor.Record{AttrValues: []string{"b"}, TimeUnix: uint64(now.UnixNano())}), "Expected the record not to be late")
+		windows := aggregator.FlushClosed(true)
+		require.Len(t, windows, 2)
+		assert.Equal(t, int64(1), windows[0].Count("b"))
+		assert.Equal(t, int64(1), windows[1].Count("a"))
+	})
+
+	t.Run("rejects unknown settings", func(t *testing.T) {
+		_, err := ingestor.NewAggregator(config.Config{Shards: 1, WindowTime: "ingest"})
+		assert.Error(t, err)
+
+		_, err = ingestor.NewAggregator(config.Config{Shards: 1, LateDataPolicy: "retry"})
+		assert.Error(t, err)
+
+		_, err = ingestor.NewAggregator(config.Config{Shards: 1, AggregationWindow: time.Minute, WindowSlide: 7 * time.Second})
+		assert.Error(t, err, "the window must be a multiple of the slide")
+	})
+}
+
+func TestAggregatorValues(t *testing.T) {
+	aggregator, err := ingestor.NewAggregator(config.Config{Shards: 4})
+	require.NoError(t, err)
+
+	for _, v := range []float64{120, 80, 250} {
+		aggregator.Add(ingestor.Record{AttrValues: []string{"checkout"}, Value: v, HasValue: true})
+	}
+	// Records without a value are still counted.
+	aggregator.Add(ingestor.Record{AttrValues: []string{"checkout"}})
+	aggregator.Add(ingestor.Record{AttrValues: []string{"cart"}})
+
+	snapshot := aggregator.Flush()
+	require.Len(t, snapshot.Groups, 2)
+
+	cart, checkout := snapshot.Groups[0], snapshot.Groups[1]
+	assert.Equal(t, int64(1), cart.Count)
+	assert.Zero(t, cart.Value.Count)
+
+	assert.Equal(t, int64(4), checkout.Count)
+	assert.Equal(t, ingestor.ValueStats{Count: 3, Sum: 450, Min: 80, Max: 250}, checkout.Value)
+	assert.Equal(t, 150.0, checkout.Value.Mean())
+}
+
+func TestAggregatorDistributions(t *testing.T) {
+	aggregator, err := ingestor.NewAggregator(config.Config{Shards: 4, DistributionMaxBuckets: 160})
+	require.NoError(t, err)
+
+	// Latencies from 1ms to 1h, uniformly spread over the range in milliseconds.
+	for v := 1.0; v <= 3_600_000; v += 997 {
+		aggregator.Add(ingestor.Record{AttrValues: []string{"checkout"}, Distribution: v, HasDistribution: true})
+	}
+	aggregator.Add(ingestor.Record{AttrValues: []string{"cart"}})
+
+	snapshot := aggregator.Flush()
+	require.Len(t, snapshot.Groups, 2)
+	assert.Nil(t, snapshot.Groups[0].Distribution)
+
+	h := snapshot.Groups[1].Distribution
+	require.NotNil(t, h)
+	assert.Equal(t, uint64(snapshot.Groups[1].Count), h.Count)
+	assert.Equal(t, 1.0, h.Min)
+	assert.Equal(t, 1.0, h.Quantile(0))
+
+	for _, q := range []float64{0.5, 0.95, 0.99} {
+		assert.InEpsilon(t, q*3_600_000, h.Quantile(q), 0.1, "quantile %g", q)
+	}
+}
+
+func TestAggregatorDistributionQuantiles(t *testing.T) {
+	for _, q := range []float64{-0.1, 1.5} {
+		_, err := ingestor.NewAggregator(config.Config{Shards: 1, DistributionQuantiles: []float64{0.5, q}})
+		assert.Error(t, err, "quantile %g", q)
+	}
+
+	_, err := ingestor.NewAggregator(config.Config{Shards: 1, DistributionQuantiles: []float64{0, 0.99, 1}})
+	assert.NoError(t, err)
+}
+
+func TestAggregatorDistinct(t *testing.T) {
+	t.Run("estimates the distinct values per group", func(t *testing.T) {
+		aggregator, err := ingestor.NewAggregator(config.Config{Shards: 4, DistinctPrecision: 14})
+		require.NoError(t, err)
+
+		// Every user is seen several times.
+		for i := range 30_000 {
+			aggregator.Add(ingestor.Record{
+				AttrValues:  []string{"checkout"},
+				Distinct:    fmt.Sprintf("user-%d", i%10_000),
+				HasDistinct: true,
+			})
+		}
+		aggregator.Add(ingestor.Record{AttrValues: []string{"cart"}})
+
+		snapshot := aggregator.Flush()
+		require.Len(t, snapshot.Groups, 2)
+		assert.Zero(t, snapshot.Groups[0].Distinct)
+		assert.InEpsilon(t, 10_000, snapshot.Groups[1].Distinct, 0.03)
+	})
+
+	t.Run("rejects an out of range precision", func(t *testing.T) {
+		_, err := ingestor.NewAggregator(config.Config{Shards: 4, DistinctPrecision: 19})
+		assert.Error(t, err)
+	})
+}
+
+func TestAggregatorTopK(t *testing.T) {
+	t.Run("reports the most frequent groups within the error bound", func(t *testing.T) {
+		aggregator, err := ingestor.NewAggregator(config.Config{
+			Shards:          4,
+			AggregationMode: ingestor.AggregationModeTopK,
+			TopK:            3,
+			TopKCounters:    40,
+		})
+		require.NoError(t, err)
+
+		// Three heavy hitters among 5000 distinct values seen once.
+		heavy := map[string]int64{"10.0.0.1": 3000, "10.0.0.2": 2000, "10.0.0.3": 1000}
+		for ip, n := range heavy {
+			for range n {
+				aggregator.Add(ingestor.Record{AttrValues: []string{ip}})
+			}
+		}
+		for i := range 5000 {
+			aggregator.Add(ingestor.Record{AttrValues: []string{fmt.Sprintf("192.168.%d.%d", i/256, i%256)}})
+		}
+
+		snapshot := aggregator.Flush()
+		require.NotNil(t, snapshot.TopK)
+		assert.Equal(t, int64(11_000), snapshot.TopK.Records)
+		assert.Positive(t, snapshot.TopK.ErrorBound)
+		assert.LessOrEqual(t, len(snapshot.Groups), 40, "Memory is bounded by the counters")
+
+		for i, ip := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"} {
+			g := snapshot.Groups[i]
+			assert.Equal(t, []string{ip}, g.Values)
+			assert.GreaterOrEqual(t, g.Count, heavy[ip])
+			assert.LessOrEqual(t, g.Count-g.CountError, heavy[ip])
+			assert.LessOrEqual(t, g.CountError, snapshot.TopK.ErrorBound)
+		}
+	})
+
+	t.Run("ranks groups by their weighted count", func(t *testing.T) {
+		aggregator, err := ingestor.NewAggregator(config.Config{
+			Shards:          1,
+			AggregationMode: ingestor.AggregationModeTopK,
+			TopK:            1,
+			TopKCounters:    2,
+		})
+		require.NoError(t, err)
+
+		// A service sampled at 1% must outrank a noisier unsampled one, and
+		// survive the evictions caused by values seen once.
+		for range 3 {
+			aggregator.Add(ingestor.Record{AttrValues: []string{"sampled"}, Weight: 100})
+		}
+		for range 50 {
+			aggregator.Add(ingestor.Record{AttrValues: []string{"noisy"}})
+		}
+		for i := range 20 {
+			aggregator.Add(ingestor.Record{AttrValues: []string{fmt.Sprintf("once-%d", i)}})
+		}
+
+		snapshot := aggregator.Flush()
+		assert.Equal(t, int64(370), snapshot.TopK.Records)
+		assert.Equal(t, int64(70), snapshot.TopK.ErrorBound, "The evicted counts are weighted")
+
+		g := snapshot.Groups[0]
+		assert.Equal(t, []string{"sampled"}, g.Values)
+		assert.Equal(t, int64(300), g.Count)
+		assert.Zero(t, g.CountError)
+
+		assert.Equal(t, int64(70), snapshot.Groups[1].Count)
+		assert.Equal(t, int64(69), snapshot.Groups[1].CountError)
+	})
+
+	t.Run("is exact while every group fits", func(t *testing.T) {
+		aggregator, err := ingestor.NewAggregator(config.Config{
+			Shards:          4,
+			AggregationMode: ingestor.AggregationModeTopK,
+			TopK:            2,
+		})
+		require.NoError(t, err)
+
+		for _, v := range []string{"a", "b", "b", "c", "c", "c"} {
+			aggregator.Add(ingestor.Record{AttrValues: []string{v}})
+		}
+
+		snapshot := aggregator.Flush()
+		assert.Zero(t, snapshot.TopK.ErrorBound)
+		require.Len(t, snapshot.Groups, 3)
+		assert.Equal(t, []string{"c"}, snapshot.Groups[0].Values)
+		assert.Equal(t, int64(3), snapshot.Groups[0].Count)
+		assert.Zero(t, snapshot.Groups[0].CountError)
+	})
+
+	t.Run("rejects invalid settings", func(t *testing.T) {
+		for _, cfg := range []config.Config{
+			{Shards: 4, AggregationMode: "approximate"},
+			{Shards: 4, AggregationMode: ingestor.AggregationModeTopK, TopK: 0},
+			{Shards: 4, AggregationMode: ingestor.AggregationModeTopK, TopK: 10, TopKCounters: 5},
+		} {
+			_, err := ingestor.NewAggregator(cfg)
+			assert.Error(t, err, "%+v", cfg)
+		}
+	})
+}
+
+func TestAggregatorSeverityBreakdown(t *testing.T) {
+	aggregator, err := ingestor.NewAggregator(config.Config{Shards: 4, SeverityBreakdown: true})
+	require.NoError(t, err)
+
+	// INFO, INFO2, WARN, ERROR4 and an unspecified severity.
+	for _, severity := range []int32{9, 10, 13, 20, 0} {
+		aggregator.Add(ingestor.Record{AttrValues: []string{"checkout"}, Severity: severity})
+	}
+
+	snapshot := aggregator.Flush()
+	require.Len(t, snapshot.Groups, 1)
+
+	severities := snapshot.Groups[0].Severities
+	assert.Equal(t, int64(2), severities[ingestor.SeverityInfo])
+	assert.Equal(t, int64(1), severities[ingestor.SeverityWarn])
+	assert.Equal(t, int64(1), severities[ingestor.SeverityError])
+	assert.Equal(t, int64(1), severities[ingestor.SeverityUnspecified])
+	assert.Equal(t, "UNSPECIFIED=1 INFO=2 WARN=1 ERROR=1", severities.String())
+}
+
+func TestSeverityRangeOf(t *testing.T) {
+	for severityNumber, expected := range map[int32]ingestor.SeverityRange{
+		0:  ingestor.SeverityUnspecified,
+		1:  ingestor.SeverityTrace,
+		8:  ingestor.SeverityDebug,
+		9:  ingestor.SeverityInfo,
+		16: ingestor.SeverityWarn,
+		17: ingestor.SeverityError,
+		24: ingestor.SeverityFatal,
+		25: ingestor.SeverityUnspecified,
+	} {
+		assert.Equal(t, expected, ingestor.SeverityRangeOf(severityNumber), "severity number %d", severityNumber)
+	}
+}
+
+func TestAggregatorWeights(t *testing.T) {
+	aggregator, err := ingestor.NewAggregator(config.Config{Shards: 4})
+	require.NoError(t, err)
+
+	// Three records sampled at a third and one unweighted record.
+	for range 3 {
+		aggregator.Add(ingestor.Record{AttrValues: []string{"checkout"}, Weight: 3})
+	}
+	aggregator.Add(ingestor.Record{AttrValues: []string{"checkout"}})
+
+	snapshot := aggregator.Flush()
+	assert.Equal(t, int64(10), snapshot.Count("checkout"))
 }
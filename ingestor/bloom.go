@@ -0,0 +1,202 @@
+package ingestor
+
+import (
+	"math"
+
+	"github.com/miguelhrocha/otel-collector/internal/hashutil"
+)
+
+const (
+	// bloomGrowth is the capacity multiplier of each new filter in a scalableBloomFilter.
+	bloomGrowth = 2
+
+	// bloomTightening is the false positive rate multiplier of each new filter in a scalableBloomFilter.
+	//
+	// With a ratio r, the compound false positive rate of all filters is bounded by p0 / (1 - r).
+	bloomTightening = 0.5
+)
+
+// scalableBloomFilter is a scalable Bloom filter (Almeida et al., 2007).
+//
+// It starts with a single Bloom filter sized for an initial capacity, and adds
+// filters of growing capacity and tightening false positive rate as the
+// previous ones fill up, keeping the compound false positive rate below the
+// configured target.
+//
+// New filters are only added while they fit within maxBytes. Past that, entries
+// keep being added to the last filter and the false positive rate rises above
+// the target instead of the memory usage.
+type scalableBloomFilter struct {
+	filters []*bloomFilter
+
+	initialCapacity int
+	fpRate          float64
+	maxBytes        int
+}
+
+func newScalableBloomFilter(initialCapacity int, fpRate float64, maxBytes int) *scalableBloomFilter {
+	sbf := &scalableBloomFilter{
+		initialCapacity: initialCapacity,
+		fpRate:          fpRate,
+		maxBytes:        maxBytes,
+	}
+	sbf.reset()
+	return sbf
+}
+
+// contains reports whether the hash is (probably) in the filter.
+func (sbf *scalableBloomFilter) contains(hash uint64) bool {
+	// Record hashes also pick the deduplicator shard, so scramble them
+	// before deriving the bit positions.
+	h := hashutil.Mix64(hash)
+
+	for _, f := range sbf.filters {
+		if f.test(h) {
+			return true
+		}
+	}
+	return false
+}
+
+// testAndAdd adds the hash to the filter, reporting whether it was (probably) already present.
+func (sbf *scalableBloomFilter) testAndAdd(hash uint64) bool {
+	if sbf.contains(hash) {
+		return true
+	}
+
+	h := hashutil.Mix64(hash)
+
+	current := sbf.filters[len(sbf.filters)-1]
+	if current.count >= current.capacity {
+		if next := sbf.nextFilter(); next != nil && sbf.bytes()+next.bytes() <= sbf.maxBytes {
+			sbf.filters = append(sbf.filters, next)
+			current = next
+		}
+	}
+
+	current.add(h)
+	return false
+}
+
+func (sbf *scalableBloomFilter) reset() {
+	sbf.filters = []*bloomFilter{
+		newBloomFilter(sbf.initialCapacity, initialBloomFPRate(sbf.fpRate)),
+	}
+}
+
+// initialBloomFPRate is the false positive rate of the first filter of a
+// scalableBloomFilter, so that the compound rate of all filters stays below fpRate.
+func initialBloomFPRate(fpRate float64) float64 {
+	return fpRate * (1 - bloomTightening)
+}
+
+func (sbf *scalableBloomFilter) nextFilter() *bloomFilter {
+	last := sbf.filters[len(sbf.filters)-1]
+	return newBloomFilter(last.capacity*bloomGrowth, last.fpRate*bloomTightening)
+}
+
+func (sbf *scalableBloomFilter) bytes() int {
+	n := 0
+	for _, f := range sbf.filters {
+		n += f.bytes()
+	}
+	return n
+}
+
+func (sbf *scalableBloomFilter) stats() setStats {
+	var (
+		setBits, totalBits uint64
+		notFalsePositive   = 1.0
+	)
+
+	for _, f := range sbf.filters {
+		setBits += f.setBits
+		totalBits += f.bits
+		notFalsePositive *= 1 - f.estimatedFPRate()
+	}
+
+	return setStats{
+		entries:           sbf.entries(),
+		bytes:             sbf.bytes(),
+		fillRatio:         float64(setBits) / float64(totalBits),
+		falsePositiveRate: 1 - notFalsePositive,
+	}
+}
+
+func (sbf *scalableBloomFilter) entries() int {
+	n := 0
+	for _, f := range sbf.filters {
+		n += f.count
+	}
+	return n
+}
+
+// bloomFilter is a classic Bloom filter using double hashing.
+type bloomFilter struct {
+	words []uint64
+	bits  uint64
+	k     uint64
+
+	capacity int
+	fpRate   float64
+
+	// count is the number of entries added, setBits the number of bits set.
+	count   int
+	setBits uint64
+}
+
+// newBloomFilter creates a Bloom filter holding capacity entries at the given false positive rate,
+// which must be between 0 and 1 exclusive.
+func newBloomFilter(capacity int, fpRate float64) *bloomFilter {
+	capacity = max(capacity, 1)
+	words, k := bloomFilterSize(capacity, fpRate)
+	return &bloomFilter{
+		words:    make([]uint64, words),
+		bits:     words * 64,
+		k:        k,
+		capacity: capacity,
+		fpRate:   fpRate,
+	}
+}
+
+// bloomFilterSize returns the optimal number of 64-bit words and hash functions
+// of a Bloom filter holding capacity entries at the given false positive rate.
+func bloomFilterSize(capacity int, fpRate float64) (words, k uint64) {
+	m := math.Ceil(-float64(capacity) * math.Log(fpRate) / (math.Ln2 * math.Ln2))
+	// Keep at least one word, so bit positions can always be derived.
+	words = max((uint64(m)+63)/64, 1)
+	return words, uint64(math.Max(1, math.Round(m/float64(capacity)*math.Ln2)))
+}
+
+func (f *bloomFilter) test(h uint64) bool {
+	h1, h2 := h&0xffffffff, h>>32
+	for i := uint64(0); i < f.k; i++ {
+		bit := (h1 + i*h2) % f.bits
+		if f.words[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *bloomFilter) add(h uint64) {
+	h1, h2 := h&0xffffffff, h>>32
+	for i := uint64(0); i < f.k; i++ {
+		bit := (h1 + i*h2) % f.bits
+		mask := uint64(1) << (bit % 64)
+		if f.words[bit/64]&mask == 0 {
+			f.words[bit/64] |= mask
+			f.setBits++
+		}
+	}
+	f.count++
+}
+
+func (f *bloomFilter) bytes() int {
+	return len(f.words) * 8
+}
+
+// estimatedFPRate estimates the current false positive rate from the fraction of bits set.
+func (f *bloomFilter) estimatedFPRate() float64 {
+	return math.Pow(float64(f.setBits)/float64(f.bits), float64(f.k))
+}
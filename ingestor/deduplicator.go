@@ -2,7 +2,9 @@ package ingestor
 
 import (
 	"encoding/binary"
+	"fmt"
 	"sync"
+	"time"
 
 	"github.com/miguelhrocha/otel-collector/config"
 	"github.com/segmentio/fasthash/fnv1a"
@@ -16,32 +18,155 @@ var (
 	capacityPerShard = 32 * 1024 // 32K entries per shard
 )
 
+const (
+	// DedupBackendExact keeps the exact hash of every record seen.
+	DedupBackendExact = "exact"
+
+	// DedupBackendBloom keeps the record hashes in a scalable Bloom filter.
+	DedupBackendBloom = "bloom"
+
+	// DedupBackendNone disables deduplication, every record is considered new.
+	DedupBackendNone = "none"
+)
+
 // Deduplicator handles deduplication of incoming logs.
 //
 // Logs in OTEL format can sometimes be duplicated due to various reasons such as network issues or retries.
 // The Deduplicator struct is responsible for identifying and removing these duplicate logs before further processing.
+//
+// The seen records are kept either in an exact set, which grows with the number
+// of records, or in a scalable Bloom filter with bounded memory that may report
+// a small fraction of new records as duplicates. See config.DedupBackend.
+//
+// The fields identifying a record are configurable, see config.DedupFields.
+//
+// Records are remembered for a TTL independent of the aggregation window, so
+// retries straddling a window boundary are still caught. Each shard keeps two
+// generations of seen records and rotates them every TTL: new records go to the
+// current generation, which becomes the previous one on rotation, while the
+// previous one is discarded. A record is therefore remembered for at least TTL
+// and at most twice that.
 type Deduplicator struct {
 	shards []deduplicatorShard
+	fields []identityField
+	ttl    time.Duration
+	now    func() time.Time
+
+	// disabled makes every record new, see DedupBackendNone.
+	disabled bool
 }
 
 type deduplicatorShard struct {
-	mu       sync.Mutex
-	seen     map[uint64]struct{}
-	capacity int
+	mu sync.Mutex
+
+	// current receives new records, previous holds the records of the previous generation.
+	current  seenSet
+	previous seenSet
+
+	rotatedAt time.Time
+}
+
+// seenSet is the set of record hashes seen by a deduplicator shard.
+type seenSet interface {
+	// contains reports whether the hash is in the set.
+	contains(hash uint64) bool
+
+	// testAndAdd adds the hash to the set, reporting whether it was already present.
+	testAndAdd(hash uint64) bool
+
+	// reset removes all hashes from the set.
+	reset()
+
+	stats() setStats
+}
+
+type setStats struct {
+	entries           int
+	bytes             int
+	fillRatio         float64
+	falsePositiveRate float64
+}
+
+// DedupStats describes the state of a Deduplicator.
+type DedupStats struct {
+	// Entries is the number of record hashes held.
+	Entries int
+
+	// Bytes is the approximate memory used by the Bloom filters.
+	// It is only reported by the bloom backend.
+	Bytes int
+
+	// FillRatio is the fraction of bits set in the Bloom filters.
+	// It is always 0 for the exact backend.
+	FillRatio float64
+
+	// FalsePositiveRate is the estimated probability of a new record being reported as a duplicate.
+	// It is always 0 for the exact backend.
+	FalsePositiveRate float64
 }
 
 // NewDeduplicator creates a new Dedupe instance with the specified number of shards and capacity per shard.
-func NewDeduplicator(cfg config.Config) *Deduplicator {
+//
+// Records are remembered for the config's DedupTTL, or for the AggregationWindow if unset.
+//
+// It returns an error if the config's DedupBackend is not supported or its DedupFields are invalid,
+// or, for the bloom backend, if the DedupFalsePositiveRate is not between 0 and 1 exclusive
+// or the initial filters do not fit in the DedupMaxMemory.
+func NewDeduplicator(cfg config.Config) (*Deduplicator, error) {
+	fields, err := parseIdentityFields(cfg.DedupFields)
+	if err != nil {
+		return nil, err
+	}
+
+	var newSet func() seenSet
+
+	switch cfg.DedupBackend {
+	case DedupBackendNone:
+		return &Deduplicator{disabled: true, now: time.Now}, nil
+	case DedupBackendExact, "":
+		newSet = func() seenSet {
+			return newExactSet(capacityPerShard)
+		}
+	case DedupBackendBloom:
+		if !(cfg.DedupFalsePositiveRate > 0 && cfg.DedupFalsePositiveRate < 1) {
+			return nil, fmt.Errorf("deduplication false positive rate must be between 0 and 1 exclusive, got %g", cfg.DedupFalsePositiveRate)
+		}
+
+		// Each shard holds two generations of filters.
+		maxBytesPerShard := cfg.DedupMaxMemory / max(cfg.Shards, 1) / 2
+		words, _ := bloomFilterSize(capacityPerShard, initialBloomFPRate(cfg.DedupFalsePositiveRate))
+		if initialBytes := int(words) * 8; initialBytes > maxBytesPerShard {
+			return nil, fmt.Errorf("deduplication max memory of %d bytes is too small for %d shards, each needs at least %d bytes",
+				cfg.DedupMaxMemory, max(cfg.Shards, 1), 2*initialBytes)
+		}
+
+		newSet = func() seenSet {
+			return newScalableBloomFilter(capacityPerShard, cfg.DedupFalsePositiveRate, maxBytesPerShard)
+		}
+	default:
+		return nil, fmt.Errorf("unknown deduplication backend %q", cfg.DedupBackend)
+	}
+
+	ttl := cfg.DedupTTL
+	if ttl <= 0 {
+		ttl = cfg.AggregationWindow
+	}
+
+	now := time.Now()
 	s := make([]deduplicatorShard, cfg.Shards)
 	for i := range s {
 		s[i] = deduplicatorShard{
-			seen:     make(map[uint64]struct{}, capacityPerShard),
-			capacity: capacityPerShard,
+			current:   newSet(),
+			previous:  newSet(),
+			rotatedAt: now,
 		}
 	}
 	return &Deduplicator{
 		shards: s,
-	}
+		fields: fields,
+		ttl:    ttl,
+		now:    time.Now,
+	}, nil
 }
 
 // IsNew checks if a given Record is new (not a duplicate).
@@ -49,39 +174,138 @@ func NewDeduplicator(cfg config.Config) *Deduplicator {
 // It computes the hash of the Record and checks if it has been seen before.
 // If the Record is new, it adds its hash to the seen set and returns true.
 // If it is a duplicate, it returns false.
+//
+// It always returns true if deduplication is disabled.
 func (d *Deduplicator) IsNew(r Record) bool {
-	hash := hashRecord(r)
+	if d.disabled {
+		return true
+	}
+
+	hash := hashRecord(r, d.fields)
 	key := int(hash % uint64(len(d.shards)))
 
 	sh := &d.shards[key]
 	sh.mu.Lock()
 	defer sh.mu.Unlock()
 
-	if _, ok := sh.seen[hash]; ok {
+	sh.rotate(d.now(), d.ttl)
+
+	if sh.previous.contains(hash) {
 		return false
 	}
 
-	sh.seen[hash] = struct{}{}
-	return true
+	return !sh.current.testAndAdd(hash)
 }
 
 // Reset clears all seen records in the Dedupe instance.
 func (d *Deduplicator) Reset() {
+	now := d.now()
 	for i := range d.shards {
 		sh := &d.shards[i]
 		sh.mu.Lock()
-		sh.seen = make(map[uint64]struct{}, sh.capacity)
+		sh.current.reset()
+		sh.previous.reset()
+		sh.rotatedAt = now
 		sh.mu.Unlock()
 	}
 }
 
-// hashRecord computes a hash for a given Record based on its significant fields.
+// rotate expires the generations older than the TTL.
 //
-// This function concatenates the relevant fields of the Record, separated by null bytes,
+// Rotation happens lazily on access, so idle shards cost nothing.
+// The shard lock must be held.
+func (sh *deduplicatorShard) rotate(now time.Time, ttl time.Duration) {
+	elapsed := now.Sub(sh.rotatedAt)
+	if ttl <= 0 || elapsed < ttl {
+		return
+	}
+
+	if elapsed >= 2*ttl {
+		// Both generations have expired.
+		sh.current.reset()
+		sh.previous.reset()
+	} else {
+		// Reuse the expired generation as the new current one.
+		sh.previous.reset()
+		sh.current, sh.previous = sh.previous, sh.current
+	}
+
+	// Keep rotations aligned to multiples of the TTL.
+	sh.rotatedAt = sh.rotatedAt.Add(elapsed.Truncate(ttl))
+}
+
+// Stats returns the current state of the Deduplicator, aggregated over all shards.
+func (d *Deduplicator) Stats() DedupStats {
+	var stats DedupStats
+
+	// Records are spread evenly over the shards and only checked against their
+	// own shard, so the overall ratios are the averages of the shard ratios.
+	now := d.now()
+	for i := range d.shards {
+		sh := &d.shards[i]
+		sh.mu.Lock()
+		sh.rotate(now, d.ttl)
+		current, previous := sh.current.stats(), sh.previous.stats()
+		sh.mu.Unlock()
+
+		stats.Entries += current.entries + previous.entries
+		stats.Bytes += current.bytes + previous.bytes
+
+		// A record is checked against both generations.
+		fpRate := 1 - (1-current.falsePositiveRate)*(1-previous.falsePositiveRate)
+		stats.FalsePositiveRate += fpRate / float64(len(d.shards))
+
+		if bytes := current.bytes + previous.bytes; bytes > 0 {
+			fillRatio := (current.fillRatio*float64(current.bytes) + previous.fillRatio*float64(previous.bytes)) / float64(bytes)
+			stats.FillRatio += fillRatio / float64(len(d.shards))
+		}
+	}
+
+	return stats
+}
+
+// exactSet keeps the exact hash of every record seen.
+type exactSet struct {
+	seen     map[uint64]struct{}
+	capacity int
+}
+
+func newExactSet(capacity int) *exactSet {
+	return &exactSet{
+		seen:     make(map[uint64]struct{}, capacity),
+		capacity: capacity,
+	}
+}
+
+func (s *exactSet) contains(hash uint64) bool {
+	_, ok := s.seen[hash]
+	return ok
+}
+
+func (s *exactSet) testAndAdd(hash uint64) bool {
+	if _, ok := s.seen[hash]; ok {
+		return true
+	}
+
+	s.seen[hash] = struct{}{}
+	return false
+}
+
+func (s *exactSet) reset() {
+	s.seen = make(map[uint64]struct{}, s.capacity)
+}
+
+func (s *exactSet) stats() setStats {
+	return setStats{entries: len(s.seen)}
+}
+
+// hashRecord computes a hash for a given Record based on its identity fields.
+//
+// This function concatenates the given fields of the Record, separated by null bytes,
 // and then computes a FNV-1a hash of the resulting byte slice.
 //
 // This hash is used to identify duplicate records efficiently.
-func hashRecord(r Record) uint64 {
+func hashRecord(r Record, fields []identityField) uint64 {
 	parts := make([]byte, 0, 128)
 
 	writeSep := func() {
@@ -106,13 +330,32 @@ func hashRecord(r Record) uint64 {
 		parts = append(parts, b[:]...)
 	}
 
-	writeString(r.AttrValue)
-	writeUint64(r.TimeUnix)
-	writeUint64(r.ObsUnix)
-	writeInt32(r.Severity)
-	writeString(r.Body)
-	writeString(r.TraceID)
-	writeString(r.SpanID)
+	for _, f := range fields {
+		switch f.name {
+		case DedupFieldAttributeValues:
+			for _, v := range r.AttrValues {
+				writeString(v)
+			}
+		case DedupFieldTime:
+			writeUint64(r.TimeUnix)
+		case DedupFieldObservedTime:
+			writeUint64(r.ObsUnix)
+		case DedupFieldSeverity:
+			writeInt32(r.Severity)
+		case DedupFieldBody:
+			writeString(r.Body)
+		case DedupFieldTraceID:
+			writeString(r.TraceID)
+		case DedupFieldSpanID:
+			writeString(r.SpanID)
+		default:
+			var v string
+			if f.identity < len(r.Identity) {
+				v = r.Identity[f.identity]
+			}
+			writeString(v)
+		}
+	}
 
 	return fnv1a.HashBytes64(parts)
 }
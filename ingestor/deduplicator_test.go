@@ -1,36 +1,193 @@
 package ingestor_test
 
 import (
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/miguelhrocha/otel-collector/config"
 	"github.com/miguelhrocha/otel-collector/ingestor"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestDeduplicator(t *testing.T) {
-	deduplicator := ingestor.NewDeduplicator(config.Config{
-		Shards: 10,
+	for _, backend := range []string{ingestor.DedupBackendExact, ingestor.DedupBackendBloom} {
+		t.Run(backend, func(t *testing.T) {
+			deduplicator, err := ingestor.NewDeduplicator(config.Config{
+				Shards:                 10,
+				DedupBackend:           backend,
+				DedupFalsePositiveRate: 0.001,
+				DedupMaxMemory:         4 << 20,
+			})
+			require.NoError(t, err)
+
+			record := ingestor.Record{
+				AttrValues: []string{"test"},
+				TimeUnix:   1625079600,
+				ObsUnix:    1625079601,
+				Severity:   1,
+				Body:       "This is a test log",
+				TraceID:    "trace-id-123",
+				SpanID:     "span-id-456",
+			}
+
+			isNew := deduplicator.IsNew(record)
+			assert.True(t, isNew, "Expected the record to be new")
+
+			isNew = deduplicator.IsNew(record)
+			assert.False(t, isNew, "Expected the record to be a duplicate")
+
+			deduplicator.Reset()
+
+			isNew = deduplicator.IsNew(record)
+			assert.True(t, isNew, "Expected the record to be new after reset")
+		})
+	}
+}
+
+func TestDeduplicatorBloom(t *testing.T) {
+	deduplicator, err := ingestor.NewDeduplicator(config.Config{
+		Shards:                 4,
+		DedupBackend:           ingestor.DedupBackendBloom,
+		DedupFalsePositiveRate: 0.01,
+		DedupMaxMemory:         4 << 20,
 	})
+	require.NoError(t, err)
+
+	const n = 200_000
+
+	falsePositives := 0
+	for i := range n {
+		record := ingestor.Record{AttrValues: []string{"test"}, Body: fmt.Sprintf("log %d", i)}
+		if !deduplicator.IsNew(record) {
+			falsePositives++
+		}
+	}
+
+	for i := range n {
+		record := ingestor.Record{AttrValues: []string{"test"}, Body: fmt.Sprintf("log %d", i)}
+		assert.False(t, deduplicator.IsNew(record), "Bloom filters must never miss a duplicate")
+	}
+
+	stats := deduplicator.Stats()
+	assert.Less(t, float64(falsePositives)/n, 0.01)
+	assert.Less(t, stats.FalsePositiveRate, 0.01)
+	assert.Greater(t, stats.FillRatio, 0.0)
+	assert.LessOrEqual(t, stats.Bytes, 4<<20)
+	assert.Equal(t, n-falsePositives, stats.Entries)
+}
+
+func TestDeduplicatorBloomConfig(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		rate      float64
+		maxMemory int
+	}{
+		{name: "zero rate", rate: 0, maxMemory: 1 << 20},
+		{name: "rate of 1", rate: 1, maxMemory: 1 << 20},
+		{name: "rate above 1", rate: 1.5, maxMemory: 1 << 20},
+		{name: "negative rate", rate: -0.1, maxMemory: 1 << 20},
+		{name: "initial filters over max memory", rate: 0.001, maxMemory: 1 << 10},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ingestor.NewDeduplicator(config.Config{
+				Shards:                 1,
+				DedupBackend:           ingestor.DedupBackendBloom,
+				DedupFalsePositiveRate: tc.rate,
+				DedupMaxMemory:         tc.maxMemory,
+			})
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestDeduplicatorUnknownBackend(t *testing.T) {
+	_, err := ingestor.NewDeduplicator(config.Config{Shards: 1, DedupBackend: "cuckoo"})
+	assert.Error(t, err)
+}
+
+func TestDeduplicatorTTL(t *testing.T) {
+	for _, backend := range []string{ingestor.DedupBackendExact, ingestor.DedupBackendBloom} {
+		t.Run(backend, func(t *testing.T) {
+			deduplicator, err := ingestor.NewDeduplicator(config.Config{
+				Shards:                 2,
+				AggregationWindow:      time.Hour,
+				DedupTTL:               100 * time.Millisecond,
+				DedupBackend:           backend,
+				DedupFalsePositiveRate: 0.001,
+				DedupMaxMemory:         1 << 20,
+			})
+			require.NoError(t, err)
+
+			now := time.Unix(1_700_000_000, 0)
+			deduplicator.SetNow(func() time.Time { return now })
+
+			record := ingestor.Record{AttrValues: []string{"test"}, Body: "retried log"}
+			assert.True(t, deduplicator.IsNew(record))
+
+			// Still remembered after the first rotation.
+			now = now.Add(150 * time.Millisecond)
+			assert.False(t, deduplicator.IsNew(record), "Expected the record to be a duplicate within the TTL")
+
+			// Forgotten once both generations have expired.
+			now = now.Add(400 * time.Millisecond)
+			assert.True(t, deduplicator.IsNew(record), "Expected the record to be new after the TTL")
+			assert.Equal(t, 1, deduplicator.Stats().Entries)
+		})
+	}
+}
+
+func TestDeduplicatorFields(t *testing.T) {
+	deduplicator, err := ingestor.NewDeduplicator(config.Config{
+		Shards:       4,
+		DedupBackend: ingestor.DedupBackendExact,
+		DedupFields:  []string{"time", "body", "attr.log.record.uid", "resource.host.name"},
+	})
+	require.NoError(t, err)
 
 	record := ingestor.Record{
-		AttrValue: "test",
-		TimeUnix:  1625079600,
-		ObsUnix:   1625079601,
-		Severity:  1,
-		Body:      "This is a test log",
-		TraceID:   "trace-id-123",
-		SpanID:    "span-id-456",
+		TimeUnix: 1625079600,
+		ObsUnix:  1625079601,
+		Body:     "This is a test log",
+		Identity: []string{"uid-1", "host-a"},
 	}
+	assert.True(t, deduplicator.IsNew(record))
+
+	t.Run("ignores fields outside the identity", func(t *testing.T) {
+		retried := record
+		retried.ObsUnix = 1625079700
+		retried.AttrValues = []string{"other"}
+		assert.False(t, deduplicator.IsNew(retried))
+	})
 
-	isNew := deduplicator.IsNew(record)
-	assert.True(t, isNew, "Expected the record to be new")
+	t.Run("distinguishes identity attributes", func(t *testing.T) {
+		other := record
+		other.Identity = []string{"uid-2", "host-a"}
+		assert.True(t, deduplicator.IsNew(other))
+
+		other.Identity = []string{"uid-1", "host-b"}
+		assert.True(t, deduplicator.IsNew(other))
+	})
 
-	isNew = deduplicator.IsNew(record)
-	assert.False(t, isNew, "Expected the record to be a duplicate")
+	t.Run("rejects unknown fields", func(t *testing.T) {
+		_, err := ingestor.NewDeduplicator(config.Config{Shards: 1, DedupFields: []string{"time", "hostname"}})
+		assert.Error(t, err)
+
+		_, err = ingestor.NewDeduplicator(config.Config{Shards: 1, DedupFields: []string{"attr."}})
+		assert.Error(t, err)
+
+		_, err = ingestor.NewDeduplicator(config.Config{Shards: 1, DedupBackend: ingestor.DedupBackendNone, DedupFields: []string{"hostname"}})
+		assert.Error(t, err, "Expected the fields to be validated even with deduplication disabled")
+	})
+}
 
-	deduplicator.Reset()
+func TestDeduplicatorNone(t *testing.T) {
+	deduplicator, err := ingestor.NewDeduplicator(config.Config{Shards: 4, DedupBackend: ingestor.DedupBackendNone})
+	require.NoError(t, err)
 
-	isNew = deduplicator.IsNew(record)
-	assert.True(t, isNew, "Expected the record to be new after reset")
+	record := ingestor.Record{AttrValues: []string{"test"}, Body: "This is a test log"}
+	assert.True(t, deduplicator.IsNew(record))
+	assert.True(t, deduplicator.IsNew(record), "Expected every record to be new with deduplication disabled")
+	assert.Equal(t, ingestor.DedupStats{}, deduplicator.Stats())
 }
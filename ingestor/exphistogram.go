@@ -0,0 +1,262 @@
+package ingestor
+
+import (
+	"math"
+	"slices"
+)
+
+const (
+	// maxHistogramScale is the initial scale of exponential histograms,
+	// the highest resolution allowed by the OTLP data model.
+	maxHistogramScale = 20
+
+	// minHistogramScale is the lowest scale exponential histograms are downscaled to.
+	minHistogramScale = -10
+)
+
+// ExponentialHistogram is a base-2 exponential histogram following the
+// OTLP ExponentialHistogram data model.
+//
+// Values are counted into buckets whose boundaries grow by a factor of
+// 2^(2^-Scale). The histogram starts at the highest scale and halves its
+// resolution whenever the values no longer fit in its maximum number of
+// buckets, so its relative error is bounded while its memory is fixed.
+type ExponentialHistogram struct {
+	// Scale is the resolution of the buckets.
+	Scale int32
+
+	// ZeroCount is the number of values equal to zero.
+	ZeroCount uint64
+
+	// Positive and Negative hold the buckets of the positive values,
+	// and of the absolute negative values.
+	Positive ExponentialBuckets
+	Negative ExponentialBuckets
+
+	Count uint64
+	Sum   float64
+	Min   float64
+	Max   float64
+
+	// maxBuckets is the maximum number of buckets per sign.
+	maxBuckets int
+}
+
+// ExponentialBuckets are consecutive buckets of an ExponentialHistogram.
+//
+// Counts[i] is the number of values in the bucket of index Offset+i,
+// which holds the values in (base^(Offset+i), base^(Offset+i+1)].
+type ExponentialBuckets struct {
+	Offset int32
+	Counts []uint64
+}
+
+func newExponentialHistogram(maxBuckets int) *ExponentialHistogram {
+	return &ExponentialHistogram{
+		Scale:      maxHistogramScale,
+		maxBuckets: max(maxBuckets, 2),
+	}
+}
+
+// Quantile returns the estimated value at quantile q, between 0 and 1.
+//
+// The estimate is the midpoint of the bucket holding the quantile,
+// clamped to the minimum and maximum values. It returns 0 for an empty histogram.
+func (h *ExponentialHistogram) Quantile(q float64) float64 {
+	if h.Count == 0 {
+		return 0
+	}
+	if q <= 0 {
+		return h.Min
+	}
+	if q >= 1 {
+		return h.Max
+	}
+
+	rank := uint64(math.Ceil(q * float64(h.Count)))
+	var seen uint64
+
+	// Negative values, from the lowest.
+	for i := len(h.Negative.Counts) - 1; i >= 0; i-- {
+		seen += h.Negative.Counts[i]
+		if seen >= rank {
+			return h.clamp(-h.midpoint(h.Negative.Offset + int32(i)))
+		}
+	}
+
+	seen += h.ZeroCount
+	if seen >= rank {
+		return 0
+	}
+
+	for i, c := range h.Positive.Counts {
+		seen += c
+		if seen >= rank {
+			return h.clamp(h.midpoint(h.Positive.Offset + int32(i)))
+		}
+	}
+
+	return h.Max
+}
+
+func (h *ExponentialHistogram) midpoint(index int32) float64 {
+	return (h.lowerBound(index) + h.lowerBound(index+1)) / 2
+}
+
+// lowerBound returns the exclusive lower bound of the bucket of the given index.
+func (h *ExponentialHistogram) lowerBound(index int32) float64 {
+	return math.Exp2(math.Ldexp(float64(index), -int(h.Scale)))
+}
+
+func (h *ExponentialHistogram) clamp(v float64) float64 {
+	return min(max(v, h.Min), h.Max)
+}
+
+// add adds a value to the histogram.
+func (h *ExponentialHistogram) add(v float64) {
+	if h.Count == 0 || v < h.Min {
+		h.Min = v
+	}
+	if h.Count == 0 || v > h.Max {
+		h.Max = v
+	}
+	h.Count++
+	h.Sum += v
+
+	if v == 0 {
+		h.ZeroCount++
+		return
+	}
+
+	buckets := &h.Positive
+	if v < 0 {
+		buckets = &h.Negative
+	}
+
+	index := h.index(math.Abs(v))
+	if change := buckets.scaleChange(index, index, h.maxBuckets); change > 0 {
+		index >>= h.downscale(change)
+	}
+	buckets.increment(index, 1)
+}
+
+// index returns the index of the bucket holding the absolute value v at the current scale.
+func (h *ExponentialHistogram) index(v float64) int32 {
+	// Buckets are upper-inclusive, so exact boundaries belong to the lower bucket.
+	return int32(math.Ceil(math.Ldexp(math.Log2(v), int(h.Scale)))) - 1
+}
+
+// downscale halves the resolution of the histogram change times,
+// returning the change applied without going below the minimum scale.
+func (h *ExponentialHistogram) downscale(change int32) int32 {
+	change = max(min(change, h.Scale-minHistogramScale), 0)
+
+	h.Positive.downscale(change)
+	h.Negative.downscale(change)
+	h.Scale -= change
+	return change
+}
+
+// merge adds the values of another histogram to h.
+func (h *ExponentialHistogram) merge(other *ExponentialHistogram) {
+	if other == nil || other.Count == 0 {
+		return
+	}
+
+	if h.Count == 0 || other.Min < h.Min {
+		h.Min = other.Min
+	}
+	if h.Count == 0 || other.Max > h.Max {
+		h.Max = other.Max
+	}
+	h.Count += other.Count
+	h.Sum += other.Sum
+	h.ZeroCount += other.ZeroCount
+
+	// Bring both histograms to a common scale fitting the combined buckets.
+	positive, negative := other.Positive.clone(), other.Negative.clone()
+	if other.Scale < h.Scale {
+		h.downscale(h.Scale - other.Scale)
+	} else if other.Scale > h.Scale {
+		positive.downscale(other.Scale - h.Scale)
+		negative.downscale(other.Scale - h.Scale)
+	}
+
+	change := max(
+		h.Positive.scaleChange(positive.Offset, positive.Offset+int32(len(positive.Counts))-1, h.maxBuckets),
+		h.Negative.scaleChange(negative.Offset, negative.Offset+int32(len(negative.Counts))-1, h.maxBuckets),
+	)
+	if change > 0 {
+		change = h.downscale(change)
+		positive.downscale(change)
+		negative.downscale(change)
+	}
+
+	for i, c := range positive.Counts {
+		h.Positive.increment(positive.Offset+int32(i), c)
+	}
+	for i, c := range negative.Counts {
+		h.Negative.increment(negative.Offset+int32(i), c)
+	}
+}
+
+// clone returns a deep copy of the histogram.
+func (h *ExponentialHistogram) clone() *ExponentialHistogram {
+	c := *h
+	c.Positive = h.Positive.clone()
+	c.Negative = h.Negative.clone()
+	return &c
+}
+
+// scaleChange returns how many times the buckets must be downscaled
+// to also hold the indexes from low to high within maxBuckets.
+func (b *ExponentialBuckets) scaleChange(low, high int32, maxBuckets int) int32 {
+	if high < low {
+		return 0
+	}
+	if len(b.Counts) > 0 {
+		low = min(low, b.Offset)
+		high = max(high, b.Offset+int32(len(b.Counts))-1)
+	}
+
+	var change int32
+	for int(high-low) >= maxBuckets {
+		low >>= 1
+		high >>= 1
+		change++
+	}
+	return change
+}
+
+// increment adds n to the bucket of the given index, growing the buckets as needed.
+func (b *ExponentialBuckets) increment(index int32, n uint64) {
+	switch {
+	case len(b.Counts) == 0:
+		b.Offset = index
+		b.Counts = []uint64{0}
+	case index < b.Offset:
+		b.Counts = slices.Insert(b.Counts, 0, make([]uint64, b.Offset-index)...)
+		b.Offset = index
+	case int(index-b.Offset) >= len(b.Counts):
+		b.Counts = append(b.Counts, make([]uint64, int(index-b.Offset)-len(b.Counts)+1)...)
+	}
+	b.Counts[index-b.Offset] += n
+}
+
+// downscale merges every 2^change consecutive buckets into one.
+func (b *ExponentialBuckets) downscale(change int32) {
+	if len(b.Counts) == 0 || change <= 0 {
+		return
+	}
+
+	offset := b.Offset >> change
+	counts := make([]uint64, (b.Offset+int32(len(b.Counts))-1)>>change-offset+1)
+	for i, c := range b.Counts {
+		counts[(b.Offset+int32(i))>>change-offset] += c
+	}
+	b.Offset, b.Counts = offset, counts
+}
+
+func (b ExponentialBuckets) clone() ExponentialBuckets {
+	return ExponentialBuckets{Offset: b.Offset, Counts: slices.Clone(b.Counts)}
+}
@@ -0,0 +1,9 @@
+package ingestor
+
+import "time"
+
+// SetNow replaces the clock of the Deduplicator, and restarts its generations at the new clock's time.
+func (d *Deduplicator) SetNow(now func() time.Time) {
+	d.now = now
+	d.Reset()
+}
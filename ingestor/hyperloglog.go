@@ -0,0 +1,83 @@
+package ingestor
+
+import (
+	"math"
+	"math/bits"
+	"slices"
+
+	"github.com/miguelhrocha/otel-collector/internal/hashutil"
+)
+
+// hyperLogLog is a HyperLogLog cardinality sketch.
+//
+// It estimates the number of distinct hashes added to it using 2^precision
+// one-byte registers, with a standard error of about 1.04/sqrt(2^precision).
+type hyperLogLog struct {
+	precision uint8
+	registers []uint8
+}
+
+func newHyperLogLog(precision uint8) *hyperLogLog {
+	return &hyperLogLog{
+		precision: precision,
+		registers: make([]uint8, 1<<precision),
+	}
+}
+
+// add adds a 64-bit hash to the sketch.
+func (h *hyperLogLog) add(hash uint64) {
+	// FNV-1a hashes of short keys have poorly distributed high bits,
+	// so mix them before using them as register index and rank.
+	hash = hashutil.Mix64(hash)
+
+	index := hash >> (64 - h.precision)
+
+	// Set a sentinel bit so the rank is bounded by 64 - precision + 1.
+	w := hash<<h.precision | 1<<(h.precision-1)
+	rank := uint8(bits.LeadingZeros64(w)) + 1
+
+	if rank > h.registers[index] {
+		h.registers[index] = rank
+	}
+}
+
+// merge merges another sketch with the same precision into h.
+func (h *hyperLogLog) merge(other *hyperLogLog) {
+	for i, r := range other.registers {
+		if r > h.registers[i] {
+			h.registers[i] = r
+		}
+	}
+}
+
+// clone returns a copy of the sketch.
+func (h *hyperLogLog) clone() *hyperLogLog {
+	return &hyperLogLog{
+		precision: h.precision,
+		registers: slices.Clone(h.registers),
+	}
+}
+
+// estimate returns the estimated number of distinct hashes added to the sketch.
+func (h *hyperLogLog) estimate() uint64 {
+	m := float64(len(h.registers))
+
+	sum := 0.0
+	zeros := 0
+	for _, r := range h.registers {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+
+	alpha := 0.7213 / (1 + 1.079/m)
+	estimate := alpha * m * m / sum
+
+	// Use linear counting for small cardinalities, where the raw estimate is biased.
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+
+	return uint64(estimate + 0.5)
+}
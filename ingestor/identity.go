@@ -0,0 +1,102 @@
+package ingestor
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Fields of a log record that can participate in its deduplication identity, see config.DedupFields.
+const (
+	// DedupFieldAttributeValues is the values of the aggregation attribute keys.
+	DedupFieldAttributeValues = "attribute_values"
+
+	// DedupFieldTime is the timestamp of the log record.
+	DedupFieldTime = "time"
+
+	// DedupFieldObservedTime is the observed timestamp of the log record.
+	DedupFieldObservedTime = "observed_time"
+
+	// DedupFieldSeverity is the severity number of the log record.
+	DedupFieldSeverity = "severity"
+
+	// DedupFieldBody is the body of the log record.
+	DedupFieldBody = "body"
+
+	// DedupFieldTraceID is the trace ID of the log record.
+	DedupFieldTraceID = "trace_id"
+
+	// DedupFieldSpanID is the span ID of the log record.
+	DedupFieldSpanID = "span_id"
+
+	// DedupFieldAttributePrefix prefixes a log record attribute key, e.g. "attr.log.record.uid".
+	DedupFieldAttributePrefix = "attr."
+
+	// DedupFieldResourcePrefix prefixes a resource attribute key, e.g. "resource.host.name".
+	DedupFieldResourcePrefix = "resource."
+)
+
+// defaultDedupFields are the fields used when none are configured.
+var defaultDedupFields = []string{
+	DedupFieldAttributeValues,
+	DedupFieldTime,
+	DedupFieldObservedTime,
+	DedupFieldSeverity,
+	DedupFieldBody,
+	DedupFieldTraceID,
+	DedupFieldSpanID,
+}
+
+// IdentityAttribute is a log record or resource attribute participating in the deduplication identity of a record.
+type IdentityAttribute struct {
+	// Key is the attribute key, or a path into a nested value.
+	Key string
+
+	// Resource reports whether the attribute is read from the resource instead of the log record.
+	Resource bool
+}
+
+// IdentityAttributes returns the attributes among the given dedup fields,
+// in the order their values are expected in Record.Identity.
+func IdentityAttributes(fields []string) []IdentityAttribute {
+	var attrs []IdentityAttribute
+	for _, f := range fields {
+		if key, ok := strings.CutPrefix(f, DedupFieldAttributePrefix); ok {
+			attrs = append(attrs, IdentityAttribute{Key: key})
+		} else if key, ok := strings.CutPrefix(f, DedupFieldResourcePrefix); ok {
+			attrs = append(attrs, IdentityAttribute{Key: key, Resource: true})
+		}
+	}
+	return attrs
+}
+
+// identityField is a parsed dedup field.
+type identityField struct {
+	name string
+
+	// identity is the index into Record.Identity of an attribute field, or -1.
+	identity int
+}
+
+// parseIdentityFields validates the dedup fields, falling back to defaultDedupFields if there are none.
+func parseIdentityFields(fields []string) ([]identityField, error) {
+	if len(fields) == 0 {
+		fields = defaultDedupFields
+	}
+
+	parsed := make([]identityField, 0, len(fields))
+	identity := 0
+	for _, f := range fields {
+		switch {
+		case f == DedupFieldAttributeValues, f == DedupFieldTime, f == DedupFieldObservedTime,
+			f == DedupFieldSeverity, f == DedupFieldBody, f == DedupFieldTraceID, f == DedupFieldSpanID:
+			parsed = append(parsed, identityField{name: f, identity: -1})
+		case strings.HasPrefix(f, DedupFieldAttributePrefix) && len(f) > len(DedupFieldAttributePrefix),
+			strings.HasPrefix(f, DedupFieldResourcePrefix) && len(f) > len(DedupFieldResourcePrefix):
+			parsed = append(parsed, identityField{name: f, identity: identity})
+			identity++
+		default:
+			return nil, fmt.Errorf("unknown deduplication field %q", f)
+		}
+	}
+	return parsed, nil
+}
@@ -14,10 +14,11 @@ import (
 // It is a subset of the fields from the OTLP LogRecord.
 // It is used internally by the Ingestor to process incoming logs.
 //
-// All fields from this record are used for deduplication purposes.
+// The fields used for deduplication are configurable, see config.DedupFields.
 type Record struct {
-	// AttrValue is the value of the attribute used for aggregation.
-	AttrValue string
+	// AttrValues are the values of the attributes used for aggregation,
+	// one per configured attribute key, in the same order.
+	AttrValues []string
 
 	// TimeUnix is the timestamp of the log record in Unix nanoseconds.
 	// Set from LogRecord.TimeUnixNano.
@@ -42,6 +43,41 @@ type Record struct {
 	// SpanID is the span ID associated with the log record.
 	// Set from LogRecord.SpanId.
 	SpanID string
+
+	// Value is the numeric value of the config's ValueAttributeKey.
+	// It is only set if HasValue is true.
+	Value float64
+
+	// HasValue reports whether the log record has a numeric Value.
+	HasValue bool
+
+	// Distribution is the numeric value of the config's DistributionAttributeKey.
+	// It is only set if HasDistribution is true.
+	Distribution float64
+
+	// HasDistribution reports whether the log record has a numeric Distribution value.
+	HasDistribution bool
+
+	// Distinct is the value of the config's DistinctAttributeKey.
+	// It is only set if HasDistinct is true.
+	Distinct string
+
+	// HasDistinct reports whether the log record has a Distinct value.
+	HasDistinct bool
+
+	// Weight is the number of records the log record stands for after sampling,
+	// the inverse of its sampling ratio. Zero means the record is unweighted.
+	// See config.SamplingScaleCounts.
+	Weight float64
+
+	// Identity holds the values of the log record and resource attributes
+	// participating in deduplication, in the order given by IdentityAttributes.
+	Identity []string
+}
+
+// weight returns the number of records the log record stands for, at least 1.
+func (r Record) weight() float64 {
+	return max(r.Weight, 1)
 }
 
 // Ingestor handles ingestion of log records.
@@ -133,5 +169,7 @@ func (i *Ingestor) process(ctx context.Context, r Record) {
 		return
 	}
 
-	i.aggregator.Inc(r.AttrValue)
+	if late := i.aggregator.Add(r); late {
+		metrics.LateRecords.Add(ctx, 1)
+	}
 }
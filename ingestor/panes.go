@@ -0,0 +1,79 @@
+package ingestor
+
+import (
+	"slices"
+	"time"
+)
+
+// pane is the aggregated state of a slide interval, the unit sliding windows are built from.
+type pane struct {
+	start, end time.Time
+	snapshot   Snapshot
+}
+
+// paneRing holds the most recent panes of a sliding window.
+//
+// It never holds more than the number of panes per window,
+// which bounds the memory used by sliding windows.
+type paneRing struct {
+	size     time.Duration
+	maxPanes int
+	panes    []pane
+}
+
+func newPaneRing(size, slide time.Duration) *paneRing {
+	return &paneRing{
+		size:     size,
+		maxPanes: max(int(size/slide), 1),
+	}
+}
+
+// push adds the most recent pane, evicting the oldest one if the ring is full.
+func (r *paneRing) push(p pane) {
+	if len(r.panes) == r.maxPanes {
+		r.panes = slices.Delete(r.panes, 0, 1)
+	}
+	r.panes = append(r.panes, p)
+}
+
+// expire evicts the panes that do not overlap the window ending at end.
+func (r *paneRing) expire(end time.Time) {
+	start := end.Add(-r.size)
+
+	i := 0
+	for i < len(r.panes) && !r.panes[i].end.After(start) {
+		i++
+	}
+	r.panes = slices.Delete(r.panes, 0, i)
+}
+
+// correct merges the late records of the pane starting at start into it, if it is still held.
+func (r *paneRing) correct(start time.Time, snapshot Snapshot) {
+	for i := range r.panes {
+		if r.panes[i].start.Equal(start) {
+			r.panes[i].snapshot = mergeSnapshots(r.panes[i].snapshot, snapshot)
+			return
+		}
+	}
+}
+
+// oldest returns the start of the oldest pane held.
+func (r *paneRing) oldest() time.Time {
+	if len(r.panes) == 0 {
+		return time.Time{}
+	}
+	return r.panes[0].start
+}
+
+// window merges the panes held into a single snapshot, reporting whether there were any.
+func (r *paneRing) window() (Snapshot, bool) {
+	if len(r.panes) == 0 {
+		return Snapshot{}, false
+	}
+
+	snapshots := make([]Snapshot, len(r.panes))
+	for i, p := range r.panes {
+		snapshots[i] = p.snapshot
+	}
+	return mergeSnapshots(snapshots...), true
+}
@@ -0,0 +1,69 @@
+package ingestor
+
+import (
+	"strconv"
+	"strings"
+)
+
+// SeverityRange is a range of OTLP SeverityNumber values sharing a short name,
+// e.g. SeverityNumber 9 to 12 for INFO.
+type SeverityRange int
+
+const (
+	SeverityUnspecified SeverityRange = iota
+	SeverityTrace
+	SeverityDebug
+	SeverityInfo
+	SeverityWarn
+	SeverityError
+	SeverityFatal
+
+	severityRanges
+)
+
+var severityNames = [severityRanges]string{"UNSPECIFIED", "TRACE", "DEBUG", "INFO", "WARN", "ERROR", "FATAL"}
+
+// String returns the short name of the range, e.g. "INFO".
+func (s SeverityRange) String() string {
+	if s < 0 || s >= severityRanges {
+		return severityNames[SeverityUnspecified]
+	}
+	return severityNames[s]
+}
+
+// SeverityRangeOf returns the range of an OTLP SeverityNumber.
+//
+// Each range spans four severity numbers, from TRACE (1 to 4) to FATAL (21 to 24).
+// Zero and out of range numbers are unspecified.
+func SeverityRangeOf(severityNumber int32) SeverityRange {
+	if severityNumber < 1 || severityNumber > 24 {
+		return SeverityUnspecified
+	}
+	return SeverityRange((severityNumber-1)/4) + SeverityTrace
+}
+
+// SeverityCounts holds the number of records per severity range, indexed by SeverityRange.
+type SeverityCounts [severityRanges]int64
+
+// String formats the non-zero counts from the lowest severity, e.g. "INFO=1200 WARN=30 ERROR=4".
+func (c SeverityCounts) String() string {
+	var b strings.Builder
+	for s, n := range c {
+		if n == 0 {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(SeverityRange(s).String())
+		b.WriteByte('=')
+		b.WriteString(strconv.FormatInt(n, 10))
+	}
+	return b.String()
+}
+
+func (c *SeverityCounts) merge(other SeverityCounts) {
+	for s, n := range other {
+		c[s] += n
+	}
+}
@@ -0,0 +1,156 @@
+package ingestor
+
+import (
+	"container/heap"
+	"math"
+	"slices"
+)
+
+// TopKSummary describes a window aggregated in top-K mode, see config.AggregationMode.
+type TopKSummary struct {
+	// K is the number of most frequent groups reported.
+	K int
+
+	// Records is the number of records summarized, scaled up by their sampling
+	// weight like the counts, see config.SamplingScaleCounts.
+	Records int64
+
+	// ErrorBound is the maximum error of any group's count in the window.
+	//
+	// Counts are overestimated by at most their group's CountError, and
+	// groups missing from the summary have counts of at most ErrorBound.
+	ErrorBound int64
+}
+
+// merge adds the summary of another pane of the same window to s.
+func (s *TopKSummary) merge(other *TopKSummary) {
+	s.Records += other.Records
+	s.ErrorBound += other.ErrorBound
+}
+
+// spaceSaving is a Space-Saving summary of the most frequent keys.
+//
+// It monitors at most capacity keys. When a new key arrives and the summary is
+// full, the key with the lowest count is evicted and the new key takes over
+// its count, which becomes the new key's maximum overestimation.
+//
+// The count of any key is therefore overestimated by at most the lowest count,
+// which is at most the number of records divided by the capacity, and every key
+// occurring more often than that is guaranteed to be monitored.
+//
+// Counts are weighted by the sampling weight of the records, so keys are ranked
+// and evicted by their estimated count before sampling.
+type spaceSaving struct {
+	capacity int
+	records  float64
+	counters map[string]*counter
+
+	// heap orders the counters by count, the lowest first.
+	heap counterHeap
+}
+
+type counter struct {
+	key   string
+	group Group
+
+	// count is the weighted count of the key, including the count inherited on eviction.
+	// It replaces the group's Count, which only counts records unweighted.
+	count float64
+
+	// err is the maximum overestimation of count.
+	err float64
+
+	// index is the position of the counter in the heap.
+	index int
+}
+
+func newSpaceSaving(capacity int) *spaceSaving {
+	return &spaceSaving{
+		capacity: max(capacity, 1),
+		counters: make(map[string]*counter),
+	}
+}
+
+// counter returns the counter of a key, incremented by a record's weight,
+// monitoring the key if it is not yet.
+//
+// The caller must aggregate the record into the counter's group and then call fix.
+// A group taking over an evicted counter inherits its count but not its other
+// aggregations, which only cover the records seen since the key is monitored.
+func (s *spaceSaving) counter(key string, values []string, weight float64) *counter {
+	s.records += weight
+
+	c, ok := s.counters[key]
+	switch {
+	case ok:
+	case len(s.counters) < s.capacity:
+		c = &counter{key: key, group: Group{Values: slices.Clone(values)}}
+		s.counters[key] = c
+		heap.Push(&s.heap, c)
+	default:
+		c = s.heap[0]
+		delete(s.counters, c.key)
+
+		c.key = key
+		c.err = c.count
+		c.group = Group{Values: slices.Clone(values)}
+		s.counters[key] = c
+	}
+
+	c.count += weight
+	return c
+}
+
+// fix restores the heap order after the counter's count changed.
+func (s *spaceSaving) fix(c *counter) {
+	heap.Fix(&s.heap, c.index)
+}
+
+// errorBound returns the maximum overestimation of any count, the lowest count
+// once the summary is full. It is zero while every key seen is monitored.
+func (s *spaceSaving) errorBound() int64 {
+	if len(s.counters) < s.capacity {
+		return 0
+	}
+	return int64(math.Round(s.heap[0].count))
+}
+
+// groups returns the monitored groups, with their weighted Count and CountError set.
+func (s *spaceSaving) groups() []Group {
+	groups := make([]Group, 0, len(s.counters))
+	for _, c := range s.heap {
+		g := c.group
+		g.Count = int64(math.Round(c.count))
+		g.CountError = int64(math.Round(c.err))
+		// The weights are already counted.
+		g.sampledOut = 0
+		groups = append(groups, g)
+	}
+	return groups
+}
+
+// counterHeap is a min-heap of counters by count, see container/heap.
+type counterHeap []*counter
+
+func (h counterHeap) Len() int { return len(h) }
+
+func (h counterHeap) Less(i, j int) bool { return h[i].count < h[j].count }
+
+func (h counterHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *counterHeap) Push(x any) {
+	c := x.(*counter)
+	c.index = len(*h)
+	*h = append(*h, c)
+}
+
+func (h *counterHeap) Pop() any {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
@@ -2,7 +2,6 @@ package ingestor
 
 import (
 	"context"
-	"fmt"
 	"log/slog"
 	"time"
 
@@ -10,29 +9,123 @@ import (
 	"github.com/miguelhrocha/otel-collector/metrics"
 )
 
+// WindowResult is the result of a flushed aggregation window.
+type WindowResult struct {
+	// Start is the inclusive start of the window.
+	Start time.Time
+
+	// End is the exclusive end of the window.
+	End time.Time
+
+	// AttributeKeys are the log attribute keys the window was aggregated on.
+	AttributeKeys []string
+
+	// ValueKey is the attribute key of the numeric values aggregated in each group's Value,
+	// or empty if values are not aggregated.
+	ValueKey string
+
+	// DistributionKey is the attribute key of the values tracked in each group's Distribution,
+	// or empty if distributions are not tracked.
+	DistributionKey string
+
+	// DistinctKey is the attribute key whose distinct values are counted in each group's Distinct,
+	// or empty if distinct values are not counted.
+	DistinctKey string
+
+	// SeverityBreakdown reports whether each group's count is broken down into its Severities.
+	SeverityBreakdown bool
+
+	// Quantiles are the quantiles to report for each group's Distribution.
+	Quantiles []float64
+
+	// Groups holds the number of deduplicated log records seen in the window
+	// per combination of attribute values. Each group's Values are in the
+	// same order as AttributeKeys.
+	Groups []Group
+
+	// Overflow describes the records that exceeded the cardinality limit of the window.
+	Overflow Overflow
+
+	// TopK describes the top-K summary the groups were taken from, or is nil in exact mode.
+	// Groups then holds at most TopK.K groups, by decreasing count.
+	TopK *TopKSummary
+
+	// Correction reports whether the result holds the late records of an event-time window
+	// exported before, to be added to its previous counts. See config.LateDataPolicy.
+	Correction bool
+
+	// LateRecords is the number of late records discarded since the previous export,
+	// when late records are counted. See config.LateDataPolicy.
+	LateRecords int64
+}
+
+// Exporter exports flushed aggregation windows to a sink.
+//
+// Implementations can be found in the exporter package.
+type Exporter interface {
+	Export(ctx context.Context, result WindowResult) error
+}
+
 // WindowManager manages aggregation windows.
 //
-// It periodically flushes the current aggregation window and resets the deduplicator.
+// It periodically flushes the current aggregation window and reports the state of the deduplicator.
+// Seen records expire on their own TTL, see config.DedupTTL, so deduplication spans window boundaries.
+//
+// With processing-time windows, every flushed window, including empty ones, is handed
+// to the configured Exporter. With event-time windows, the windows closed by the
+// watermark and the corrections of late records are checked for on every tick,
+// and empty windows are never exported. See config.WindowTime.
+//
+// Sliding windows are built from the panes flushed by the Aggregator every slide
+// interval: each pane completes a window merging the panes it covers. See config.WindowSlide.
 type WindowManager struct {
-	aggregator     *Aggregator
-	deduplicator   *Deduplicator
-	windowDuration time.Duration
-	attributeKey   string
-	ticker         *time.Ticker
-	stopCh         chan struct{}
-	doneCh         chan struct{}
+	aggregator      *Aggregator
+	deduplicator    *Deduplicator
+	exporter        Exporter
+	windowDuration  time.Duration
+	windowSlide     time.Duration
+	windowStart     time.Time
+	alignWindows    bool
+	attributeKeys   []string
+	valueKey        string
+	distributionKey string
+	distinctKey     string
+	severities      bool
+	quantiles       []float64
+	timer           *time.Timer
+	stopCh          chan struct{}
+	doneCh          chan struct{}
+
+	// panes holds the most recent panes making up the current window.
+	panes *paneRing
+
+	// nextEnd is the end of the next event-time window to emit.
+	nextEnd time.Time
+
+	// nextFlush is the scheduled time of the next flush, the end of the current processing-time pane.
+	nextFlush time.Time
 }
 
 // NewWindowManager creates a new WindowManager instance.
-func NewWindowManager(cfg config.Config, a *Aggregator, d *Deduplicator) *WindowManager {
+//
+// The exporter is optional; pass nil to discard flushed windows.
+func NewWindowManager(cfg config.Config, a *Aggregator, d *Deduplicator, e Exporter) *WindowManager {
 	return &WindowManager{
-		aggregator:     a,
-		deduplicator:   d,
-		windowDuration: cfg.AggregationWindow,
-		attributeKey:   cfg.AttributeKey,
-		ticker:         time.NewTicker(cfg.AggregationWindow),
-		stopCh:         make(chan struct{}),
-		doneCh:         make(chan struct{}),
+		aggregator:      a,
+		deduplicator:    d,
+		exporter:        e,
+		windowDuration:  cfg.AggregationWindow,
+		windowSlide:     a.PaneDuration(),
+		attributeKeys:   cfg.AttributeKeys,
+		valueKey:        cfg.ValueAttributeKey,
+		distributionKey: cfg.DistributionAttributeKey,
+		distinctKey:     cfg.DistinctAttributeKey,
+		severities:      cfg.SeverityBreakdown,
+		quantiles:       cfg.DistributionQuantiles,
+		alignWindows:    cfg.AlignWindows,
+		stopCh:          make(chan struct{}),
+		doneCh:          make(chan struct{}),
+		panes:           newPaneRing(cfg.AggregationWindow, a.PaneDuration()),
 	}
 }
 
@@ -40,63 +133,177 @@ func NewWindowManager(cfg config.Config, a *Aggregator, d *Deduplicator) *Window
 //
 // It starts a goroutine that flushes the aggregation window at regular intervals.
 //
+// If windows are aligned, flushes happen at multiples of the slide interval since
+// the Unix epoch, and the first window starts at the boundary preceding Start.
+//
 // Stop the window manager by calling the Stop method.
 func (wm *WindowManager) Start(ctx context.Context) {
-	wm.ticker = time.NewTicker(wm.windowDuration)
+	wm.windowStart = time.Now()
+	if wm.alignWindows {
+		wm.windowStart = alignTime(wm.windowStart, wm.windowSlide)
+	}
+	wm.nextFlush = wm.windowStart.Add(wm.windowSlide)
+	wm.timer = time.NewTimer(time.Until(wm.nextFlush))
 
 	slog.InfoContext(ctx, "Window manager started",
 		slog.Duration("window_duration", wm.windowDuration),
-		slog.String("attribute_key", wm.attributeKey))
+		slog.Duration("window_slide", wm.windowSlide),
+		slog.Any("attribute_keys", wm.attributeKeys))
 
 	go wm.run(ctx)
 }
 
 func (wm *WindowManager) run(ctx context.Context) {
 	defer close(wm.doneCh)
-	defer wm.ticker.Stop()
+	defer wm.timer.Stop()
 
 	for {
 		select {
-		case <-wm.ticker.C:
-			wm.flushWindow(ctx)
+		case <-wm.timer.C:
+			// Schedule flushes from the previous boundary rather than from now, so they never drift.
+			wm.flushWindow(ctx, wm.nextFlush, false)
+			wm.nextFlush = wm.nextFlush.Add(wm.windowSlide)
+			wm.timer.Reset(time.Until(wm.nextFlush))
 		case <-wm.stopCh:
 			slog.InfoContext(ctx, "Window manager stopping, performing final flush")
-			wm.flushWindow(ctx)
+			wm.flushWindow(context.WithoutCancel(ctx), time.Now(), true)
 			return
 		case <-ctx.Done():
 			slog.InfoContext(ctx, "Window manager context done, performing final flush")
-			wm.flushWindow(ctx)
+			// The context is already cancelled, detach from it so the final window can still be exported.
+			wm.flushWindow(context.WithoutCancel(ctx), time.Now(), true)
 			return
 		}
 	}
 }
 
-func (wm *WindowManager) flushWindow(ctx context.Context) {
+// flushWindow flushes and exports the completed windows.
+//
+// The current processing-time pane ends at end. If final is set, event-time
+// windows are flushed regardless of the watermark.
+func (wm *WindowManager) flushWindow(ctx context.Context, end time.Time, final bool) {
 	start := time.Now()
 
-	snapshot := wm.aggregator.Flush()
+	var results []WindowResult
+	if wm.aggregator.EventTime() {
+		for _, p := range wm.aggregator.FlushClosed(final) {
+			if p.Correction {
+				results = append(results, wm.correct(p)...)
+			} else {
+				results = append(results, wm.slide(p)...)
+			}
+		}
+	} else {
+		paneStart, paneEnd := wm.windowStart, end
+		wm.windowStart = paneEnd
+
+		wm.panes.push(pane{start: paneStart, end: paneEnd, snapshot: wm.aggregator.Flush()})
+		snapshot, _ := wm.panes.window()
+		results = append(results, wm.result(wm.panes.oldest(), paneEnd, snapshot))
+	}
 	metrics.WindowFlushDuration.Record(ctx, time.Since(start).Milliseconds())
 	metrics.WindowFlushes.Add(ctx, 1)
-	metrics.CountKeys.Record(ctx, int64(len(snapshot)))
 
-	if len(snapshot) == 0 {
-		fmt.Println("aggregation window is empty")
-		return
+	for _, result := range results {
+		wm.export(ctx, result)
+	}
+
+	dedupStats := wm.deduplicator.Stats()
+	metrics.DeduplicationEntries.Record(ctx, int64(dedupStats.Entries))
+	metrics.DeduplicationFillRatio.Record(ctx, dedupStats.FillRatio)
+	metrics.DeduplicationFPRate.Record(ctx, dedupStats.FalsePositiveRate)
+}
+
+// slide returns the event-time windows completed by a closed pane.
+//
+// Besides the window ending with the pane, the windows ending between
+// the previous pane and this one are emitted if they cover any pane.
+func (wm *WindowManager) slide(p WindowSnapshot) []WindowResult {
+	var results []WindowResult
+
+	if !wm.nextEnd.IsZero() {
+		for end := wm.nextEnd; end.Before(p.End); end = end.Add(wm.windowSlide) {
+			wm.panes.expire(end)
+			snapshot, ok := wm.panes.window()
+			if !ok {
+				break
+			}
+			results = append(results, wm.result(end.Add(-wm.windowDuration), end, snapshot))
+		}
+	}
+
+	wm.panes.push(pane{start: p.Start, end: p.End, snapshot: p.Snapshot})
+	wm.panes.expire(p.End)
+	wm.nextEnd = p.End.Add(wm.windowSlide)
+
+	snapshot, _ := wm.panes.window()
+	result := wm.result(p.End.Add(-wm.windowDuration), p.End, snapshot)
+	result.LateRecords = p.Late
+	return append(results, result)
+}
+
+// correct returns the corrections of the emitted event-time windows covering a pane with late records.
+//
+// The late records are also merged into the pane, if still held, so later windows include them.
+func (wm *WindowManager) correct(p WindowSnapshot) []WindowResult {
+	wm.panes.correct(p.Start, p.Snapshot)
+
+	var results []WindowResult
+	for end := p.End; end.Before(wm.nextEnd) && !end.After(p.Start.Add(wm.windowDuration)); end = end.Add(wm.windowSlide) {
+		result := wm.result(end.Add(-wm.windowDuration), end, p.Snapshot)
+		result.Correction = true
+		results = append(results, result)
+	}
+	return results
+}
+
+// alignTime returns the latest multiple of d since the Unix epoch not after t.
+func alignTime(t time.Time, d time.Duration) time.Time {
+	ns := t.UnixNano()
+	return time.Unix(0, ns-mod(ns, int64(d)))
+}
+
+func (wm *WindowManager) result(start, end time.Time, snapshot Snapshot) WindowResult {
+	// The summary monitors more groups than reported to bound their error.
+	groups := snapshot.Groups
+	if t := snapshot.TopK; t != nil && len(groups) > t.K {
+		groups = groups[:t.K]
 	}
 
-	// startTime := time.Now()
-	// slog.InfoContext(ctx, "Flushed aggregation window",
-	// 	slog.Int("unique_keys", len(snapshot)),
-	// 	slog.Duration("flush_duration", time.Since(startTime)))
+	return WindowResult{
+		Start:             start,
+		End:               end,
+		AttributeKeys:     wm.attributeKeys,
+		ValueKey:          wm.valueKey,
+		DistributionKey:   wm.distributionKey,
+		DistinctKey:       wm.distinctKey,
+		SeverityBreakdown: wm.severities,
+		Quantiles:         wm.quantiles,
+		Groups:            groups,
+		Overflow:          snapshot.Overflow,
+		TopK:              snapshot.TopK,
+	}
+}
 
-	// print snapshot
-	fmt.Println("aggregation window")
-	for k, v := range snapshot {
-		fmt.Printf("%s - %d\n", k, v)
+func (wm *WindowManager) export(ctx context.Context, result WindowResult) {
+	metrics.CountKeys.Record(ctx, int64(len(result.Groups)))
+	metrics.OverflowRecords.Add(ctx, result.Overflow.Records)
+	metrics.OverflowKeys.Record(ctx, result.Overflow.Keys)
+
+	if result.Overflow.Records > 0 {
+		slog.WarnContext(ctx, "Aggregation window exceeded its cardinality limit",
+			slog.Time("window_start", result.Start),
+			slog.Int64("overflow_records", result.Overflow.Records),
+			slog.Int64("overflow_keys", result.Overflow.Keys))
 	}
-	fmt.Println("-----")
 
-	wm.deduplicator.Reset()
+	if wm.exporter == nil {
+		return
+	}
+
+	if err := wm.exporter.Export(ctx, result); err != nil {
+		slog.ErrorContext(ctx, "Failed to export aggregation window", slog.Any("error", err))
+	}
 }
 
 // Stop stops the WindowManager.
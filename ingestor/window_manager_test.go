@@ -0,0 +1,177 @@
+package ingestor_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+
+	"github.com/miguelhrocha/otel-collector/config"
+	"github.com/miguelhrocha/otel-collector/ingestor"
+	"github.com/miguelhrocha/otel-collector/metrics"
+)
+
+type recordingExporter struct {
+	mu      sync.Mutex
+	results []ingestor.WindowResult
+}
+
+func (e *recordingExporter) Export(_ context.Context, r ingestor.WindowResult) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.results = append(e.results, r)
+	return nil
+}
+
+func TestWindowManagerSlidingWindows(t *testing.T) {
+	require.NoError(t, metrics.InitMetrics(otel.Meter("test")))
+
+	cfg := config.Config{
+		Shards:            4,
+		AttributeKeys:     []string{"service"},
+		AggregationWindow: 40 * time.Second,
+		WindowSlide:       10 * time.Second,
+		WindowTime:        ingestor.WindowTimeEvent,
+	}
+
+	aggregator, err := ingestor.NewAggregator(cfg)
+	require.NoError(t, err)
+	deduplicator, err := ingestor.NewDeduplicator(cfg)
+	require.NoError(t, err)
+
+	exporter := &recordingExporter{}
+	windowManager := ingestor.NewWindowManager(cfg, aggregator, deduplicator, exporter)
+	windowManager.Start(context.Background())
+
+	base := time.Unix(1000, 0)
+	for _, r := range []struct {
+		value  string
+		offset time.Duration
+	}{
+		{"a", time.Second},
+		{"b", 12 * time.Second},
+		{"a", 35 * time.Second},
+		{"c", 61 * time.Second},
+	} {
+		aggregator.Add(ingestor.Record{AttrValues: []string{r.value}, TimeUnix: uint64(base.Add(r.offset).UnixNano())})
+	}
+
+	// The final flush closes every pane.
+	windowManager.Stop()
+
+	type window struct {
+		start, end time.Duration
+		counts     map[string]int64
+	}
+	expected := []window{
+		{-30 * time.Second, 10 * time.Second, map[string]int64{"a": 1}},
+		{-20 * time.Second, 20 * time.Second, map[string]int64{"a": 1, "b": 1}},
+		// No pane ends at 30s, but the window still covers earlier panes.
+		{-10 * time.Second, 30 * time.Second, map[string]int64{"a": 1, "b": 1}},
+		{0, 40 * time.Second, map[string]int64{"a": 2, "b": 1}},
+		{10 * time.Second, 50 * time.Second, map[string]int64{"a": 1, "b": 1}},
+		{20 * time.Second, 60 * time.Second, map[string]int64{"a": 1}},
+		{30 * time.Second, 70 * time.Second, map[string]int64{"a": 1, "c": 1}},
+	}
+
+	require.Len(t, exporter.results, len(expected))
+	for i, w := range expected {
+		result := exporter.results[i]
+		assert.Equal(t, base.Add(w.start), result.Start, "window %d", i)
+		assert.Equal(t, base.Add(w.end), result.End, "window %d", i)
+
+		counts := make(map[string]int64)
+		for _, g := range result.Groups {
+			counts[g.Values[0]] = g.Count
+		}
+		assert.Equal(t, w.counts, counts, "window %d", i)
+	}
+}
+
+func TestWindowManagerAlignedWindows(t *testing.T) {
+	require.NoError(t, metrics.InitMetrics(otel.Meter("test")))
+
+	const window = 100 * time.Millisecond
+
+	cfg := config.Config{
+		Shards:            4,
+		AttributeKeys:     []string{"service"},
+		AggregationWindow: window,
+		AlignWindows:      true,
+	}
+
+	aggregator, err := ingestor.NewAggregator(cfg)
+	require.NoError(t, err)
+	deduplicator, err := ingestor.NewDeduplicator(cfg)
+	require.NoError(t, err)
+
+	exporter := &recordingExporter{}
+	windowManager := ingestor.NewWindowManager(cfg, aggregator, deduplicator, exporter)
+	windowManager.Start(context.Background())
+
+	time.Sleep(3 * window)
+	windowManager.Stop()
+
+	// The last window is cut short by the final flush.
+	require.GreaterOrEqual(t, len(exporter.results), 3)
+	for i, result := range exporter.results[:len(exporter.results)-1] {
+		assert.Zero(t, result.Start.UnixNano()%int64(window), "window %d should start on a boundary", i)
+		assert.Equal(t, window, result.End.Sub(result.Start), "window %d", i)
+
+		if i > 0 {
+			assert.Equal(t, exporter.results[i-1].End, result.Start, "windows should be contiguous")
+		}
+	}
+}
+
+func TestWindowManagerDistinct(t *testing.T) {
+	require.NoError(t, metrics.InitMetrics(otel.Meter("test")))
+
+	cfg := config.Config{
+		Shards:               4,
+		AttributeKeys:        []string{"service"},
+		DistinctAttributeKey: "user.id",
+		AggregationWindow:    20 * time.Second,
+		WindowSlide:          10 * time.Second,
+		WindowTime:           ingestor.WindowTimeEvent,
+	}
+
+	aggregator, err := ingestor.NewAggregator(cfg)
+	require.NoError(t, err)
+	deduplicator, err := ingestor.NewDeduplicator(cfg)
+	require.NoError(t, err)
+
+	exporter := &recordingExporter{}
+	windowManager := ingestor.NewWindowManager(cfg, aggregator, deduplicator, exporter)
+	windowManager.Start(context.Background())
+
+	// Users 0-999 in the first pane and users 500-1499 in the second.
+	base := time.Unix(1000, 0)
+	for pane, first := range []int{0, 500} {
+		for i := range 1000 {
+			aggregator.Add(ingestor.Record{
+				AttrValues:  []string{"checkout"},
+				TimeUnix:    uint64(base.Add(time.Duration(pane) * 10 * time.Second).UnixNano()),
+				Distinct:    fmt.Sprintf("user-%d", first+i),
+				HasDistinct: true,
+			})
+		}
+	}
+
+	windowManager.Stop()
+
+	// The window covering both panes merges their sketches.
+	expected := []int64{1000, 1500}
+	require.Len(t, exporter.results, len(expected))
+	for i, distinct := range expected {
+		result := exporter.results[i]
+		assert.Equal(t, "user.id", result.DistinctKey)
+		require.Len(t, result.Groups, 1)
+		assert.InEpsilon(t, distinct, result.Groups[0].Distinct, 0.03, "window %d", i)
+	}
+}
@@ -15,22 +15,26 @@ import (
 	"github.com/miguelhrocha/otel-collector/ingestor"
 	"github.com/miguelhrocha/otel-collector/service"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestHighThroughput(t *testing.T) {
 	cfg := config.Config{
-		Addr:         ":4317",
-		AttributeKey: "foo",
-		Shards:       256,
-		QueueSize:    10000,
-		Workers:      4,
+		Addr:          ":4317",
+		AttributeKeys: []string{"foo"},
+		Shards:        256,
+		QueueSize:     10000,
+		Workers:       4,
 	}
 
-	aggregator := ingestor.NewAggregator(cfg)
-	deduplicator := ingestor.NewDeduplicator(cfg)
+	aggregator, err := ingestor.NewAggregator(cfg)
+	require.NoError(t, err)
+	deduplicator, err := ingestor.NewDeduplicator(cfg)
+	require.NoError(t, err)
 	ingestor := ingestor.NewIngestor(cfg, aggregator, deduplicator)
 
-	svc := service.NewLogService(cfg, ingestor)
+	svc, err := service.NewLogService(cfg, ingestor)
+	require.NoError(t, err)
 
 	var wg sync.WaitGroup
 
@@ -70,9 +74,9 @@ func TestHighThroughput(t *testing.T) {
 
 	snapshot := aggregator.Flush()
 
-	gotQux := snapshot["qux"]
-	gotBaz := snapshot["baz"]
-	gotBar := snapshot["bar"]
+	gotQux := snapshot.Count("qux")
+	gotBaz := snapshot.Count("baz")
+	gotBar := snapshot.Count("bar")
 
 	assert.Greater(t, gotQux, int64(0))
 	assert.Greater(t, gotBaz, int64(0))
@@ -0,0 +1,13 @@
+// Package hashutil holds the hashing helpers shared by the ingestor and the processors.
+package hashutil
+
+// Mix64 is the finalizer of MurmurHash3, used to scramble all bits of a hash,
+// such as the FNV-1a hashes whose high bits vary little between similar inputs.
+func Mix64(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
@@ -6,6 +6,7 @@ import (
 	"log"
 	"log/slog"
 	"net"
+	"net/http"
 	"os"
 	"os/signal"
 
@@ -14,11 +15,13 @@ import (
 	"go.opentelemetry.io/otel"
 	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/health"
 	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 
 	"github.com/miguelhrocha/otel-collector/config"
+	"github.com/miguelhrocha/otel-collector/exporter"
 	"github.com/miguelhrocha/otel-collector/ingestor"
 	"github.com/miguelhrocha/otel-collector/metrics"
 	internalotel "github.com/miguelhrocha/otel-collector/otel"
@@ -71,9 +74,25 @@ func run() (err error) {
 		}()
 	}
 
-	aggregator := ingestor.NewAggregator(cfg)
-	deduplicator := ingestor.NewDeduplicator(cfg)
-	windowManager := ingestor.NewWindowManager(cfg, aggregator, deduplicator)
+	aggregator, err := ingestor.NewAggregator(cfg)
+	if err != nil {
+		return err
+	}
+
+	deduplicator, err := ingestor.NewDeduplicator(cfg)
+	if err != nil {
+		return err
+	}
+
+	fanout, err := exporter.New(cfg)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, fanout.Close())
+	}()
+
+	windowManager := ingestor.NewWindowManager(cfg, aggregator, deduplicator, fanout)
 	ingestor := ingestor.NewIngestor(cfg, aggregator, deduplicator)
 
 	windowManager.Start(ctx)
@@ -84,24 +103,86 @@ func run() (err error) {
 		return err
 	}
 
-	grpcServer := grpc.NewServer(
+	tlsConfig, err := service.NewTLSConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	creds := insecure.NewCredentials()
+	if tlsConfig != nil {
+		creds = credentials.NewTLS(tlsConfig)
+	}
+
+	authenticator, err := service.NewAuthenticator(cfg)
+	if err != nil {
+		return err
+	}
+
+	serverOptions := []grpc.ServerOption{
 		grpc.StatsHandler(otelgrpc.NewServerHandler()),
 		grpc.MaxRecvMsgSize(cfg.MaxReceiveMessageSize),
-		grpc.Creds(insecure.NewCredentials()),
-	)
+		grpc.Creds(creds),
+	}
+	if authenticator != nil {
+		serverOptions = append(serverOptions,
+			grpc.UnaryInterceptor(authenticator.UnaryServerInterceptor()),
+			grpc.StreamInterceptor(authenticator.StreamServerInterceptor()),
+		)
+	}
+	grpcServer := grpc.NewServer(serverOptions...)
 
 	healthSrver := health.NewServer()
 	healthpb.RegisterHealthServer(grpcServer, healthSrver)
-	collogspb.RegisterLogsServiceServer(grpcServer, service.NewLogService(cfg, ingestor))
+	logService, err := service.NewLogService(cfg, ingestor)
+	if err != nil {
+		return err
+	}
+	collogspb.RegisterLogsServiceServer(grpcServer, logService)
 
 	go func() {
-		slog.Info("starting gRPC", "addr", cfg.Addr)
+		slog.Info("starting gRPC", "addr", cfg.Addr, "tls", tlsConfig != nil)
 		if err := grpcServer.Serve(listener); err != nil {
 			slog.Error("gRPC server error", "error", err)
 		}
 	}()
 
+	var httpServer *http.Server
+	if cfg.HTTPAddr != "" {
+		handler := service.NewLogsHTTPHandler(cfg, logService)
+		if authenticator != nil {
+			handler = authenticator.HTTPHandler(handler)
+		}
+
+		httpServer = &http.Server{
+			Addr:      cfg.HTTPAddr,
+			Handler:   handler,
+			TLSConfig: tlsConfig,
+		}
+
+		go func() {
+			slog.Info("starting HTTP", "addr", cfg.HTTPAddr, "tls", tlsConfig != nil)
+
+			var err error
+			if tlsConfig != nil {
+				// The certificate is served by the TLS config.
+				err = httpServer.ListenAndServeTLS("", "")
+			} else {
+				err = httpServer.ListenAndServe()
+			}
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				slog.Error("HTTP server error", "error", err)
+			}
+		}()
+	}
+
 	<-ctx.Done()
+	if httpServer != nil {
+		slog.Info("shutting down HTTP server")
+		if err := httpServer.Shutdown(context.Background()); err != nil {
+			slog.Error("HTTP server shutdown error", "error", err)
+		}
+	}
+
 	slog.Info("shutting down gRPC server")
 	grpcServer.GracefulStop()
 
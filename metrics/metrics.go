@@ -8,15 +8,31 @@ var (
 	LogsReceivedCounter metric.Int64Counter
 	LogsEnqueuedCounter metric.Int64Counter
 
+	FilteredRecords metric.Int64Counter
+	SampledOut      metric.Int64Counter
+	Redactions      metric.Int64Counter
+	AuthRejected    metric.Int64Counter
+
 	IngestTotal   metric.Int64Counter
 	IngestDropped metric.Int64Counter
 
 	DeduplicationSeen       metric.Int64Counter
 	DeduplicationDuplicates metric.Int64Counter
+	DeduplicationEntries    metric.Int64Gauge
+	DeduplicationFillRatio  metric.Float64Gauge
+	DeduplicationFPRate     metric.Float64Gauge
 
 	WindowFlushes       metric.Int64Counter
 	WindowFlushDuration metric.Int64Histogram
 	CountKeys           metric.Int64Gauge
+
+	OverflowRecords metric.Int64Counter
+	OverflowKeys    metric.Int64Gauge
+
+	LateRecords metric.Int64Counter
+
+	ExportFailures metric.Int64Counter
+	ExportDropped  metric.Int64Counter
 )
 
 // InitMetrics initializes all metrics used in the application.
@@ -41,6 +57,38 @@ func InitMetrics(meter metric.Meter) error {
 		return err
 	}
 
+	FilteredRecords, err = meter.Int64Counter("filter.dropped",
+		metric.WithDescription("The total number of logs dropped by the filter, by rule"),
+		metric.WithUnit("{log}"))
+
+	if err != nil {
+		return err
+	}
+
+	SampledOut, err = meter.Int64Counter("sampling.dropped",
+		metric.WithDescription("The total number of logs dropped by the sampler"),
+		metric.WithUnit("{log}"))
+
+	if err != nil {
+		return err
+	}
+
+	Redactions, err = meter.Int64Counter("redact.values",
+		metric.WithDescription("The total number of sensitive values redacted, by pattern"),
+		metric.WithUnit("{value}"))
+
+	if err != nil {
+		return err
+	}
+
+	AuthRejected, err = meter.Int64Counter("auth.rejected",
+		metric.WithDescription("The total number of requests rejected as unauthenticated, by transport"),
+		metric.WithUnit("{request}"))
+
+	if err != nil {
+		return err
+	}
+
 	IngestTotal, err = meter.Int64Counter("ingest.total",
 		metric.WithDescription("The total number of logs ingested"),
 		metric.WithUnit("{log}"))
@@ -73,6 +121,30 @@ func InitMetrics(meter metric.Meter) error {
 		return err
 	}
 
+	DeduplicationEntries, err = meter.Int64Gauge("deduplication.entries",
+		metric.WithDescription("The number of log hashes held by the deduplicator at the end of the last window"),
+		metric.WithUnit("{log}"))
+
+	if err != nil {
+		return err
+	}
+
+	DeduplicationFillRatio, err = meter.Float64Gauge("deduplication.bloom.fill_ratio",
+		metric.WithDescription("The fraction of bits set in the deduplication Bloom filters"),
+		metric.WithUnit("1"))
+
+	if err != nil {
+		return err
+	}
+
+	DeduplicationFPRate, err = meter.Float64Gauge("deduplication.bloom.false_positive_rate",
+		metric.WithDescription("The estimated probability of a new log being reported as a duplicate by the Bloom filters"),
+		metric.WithUnit("1"))
+
+	if err != nil {
+		return err
+	}
+
 	WindowFlushes, err = meter.Int64Counter("window.flushes",
 		metric.WithDescription("The total number of window flushes"),
 		metric.WithUnit("{flush}"))
@@ -97,5 +169,45 @@ func InitMetrics(meter metric.Meter) error {
 		return err
 	}
 
+	OverflowRecords, err = meter.Int64Counter("aggregator.overflow.records",
+		metric.WithDescription("The total number of logs counted into the overflow group after the cardinality limit was reached"),
+		metric.WithUnit("{log}"))
+
+	if err != nil {
+		return err
+	}
+
+	OverflowKeys, err = meter.Int64Gauge("aggregator.overflow.keys",
+		metric.WithDescription("The estimated number of distinct keys dropped into the overflow group in the last window"),
+		metric.WithUnit("{key}"))
+
+	if err != nil {
+		return err
+	}
+
+	LateRecords, err = meter.Int64Counter("aggregator.late.records",
+		metric.WithDescription("The total number of logs that arrived after their event-time window was flushed"),
+		metric.WithUnit("{log}"))
+
+	if err != nil {
+		return err
+	}
+
+	ExportFailures, err = meter.Int64Counter("export.failures",
+		metric.WithDescription("The total number of failed window exports, by exporter"),
+		metric.WithUnit("{window}"))
+
+	if err != nil {
+		return err
+	}
+
+	ExportDropped, err = meter.Int64Counter("export.dropped",
+		metric.WithDescription("The total number of windows dropped because an exporter queue was full, by exporter"),
+		metric.WithUnit("{window}"))
+
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
@@ -0,0 +1,193 @@
+package otel
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+)
+
+// bodyExtractor extracts attribute values from log bodies.
+//
+// It is the fallback for records that carry everything in the body
+// and set no attributes.
+type bodyExtractor struct {
+	// regex is run against the string representation of the body.
+	regex *regexp.Regexp
+
+	// groups maps attribute keys to the index of their capture group in regex.
+	groups map[string]int
+
+	// pointers maps attribute keys to the reference tokens of their JSON pointer.
+	pointers map[string][]string
+}
+
+func newBodyExtractor(attributeKeys []string, bodyRegex string, pointers map[string]string) (*bodyExtractor, error) {
+	b := &bodyExtractor{
+		groups:   make(map[string]int),
+		pointers: make(map[string][]string, len(pointers)),
+	}
+
+	if bodyRegex != "" {
+		re, err := regexp.Compile(bodyRegex)
+		if err != nil {
+			return nil, fmt.Errorf("compiling body regex: %w", err)
+		}
+		b.regex = re
+
+		for _, key := range attributeKeys {
+			if i := re.SubexpIndex(groupName(key)); i > 0 {
+				b.groups[key] = i
+			}
+		}
+	}
+
+	for key, pointer := range pointers {
+		tokens, err := parseJSONPointer(pointer)
+		if err != nil {
+			return nil, fmt.Errorf("parsing JSON pointer for %q: %w", key, err)
+		}
+		b.pointers[key] = tokens
+	}
+
+	return b, nil
+}
+
+// enabled reports whether any body extraction is configured.
+func (b *bodyExtractor) enabled() bool {
+	return len(b.groups) > 0 || len(b.pointers) > 0
+}
+
+// groupName returns the capture group name for an attribute key.
+func groupName(key string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || ('0' <= r && r <= '9') {
+			return r
+		}
+		return '_'
+	}, key)
+}
+
+// parsedBody holds the lazily parsed forms of a single log body,
+// so a body is matched and parsed at most once regardless of the number of keys.
+type parsedBody struct {
+	body *commonpb.AnyValue
+
+	matched bool
+	match   []string
+
+	decoded bool
+	doc     any
+}
+
+func (b *bodyExtractor) extract(key string, body *parsedBody) string {
+	if i, ok := b.groups[key]; ok && body.body != nil {
+		if !body.matched {
+			body.matched = true
+			body.match = b.regex.FindStringSubmatch(AnyValueAsString(body.body))
+		}
+
+		if i < len(body.match) && body.match[i] != "" {
+			return body.match[i]
+		}
+	}
+
+	if tokens, ok := b.pointers[key]; ok {
+		if !body.decoded {
+			body.decoded = true
+			body.doc = decodeBody(body.body)
+		}
+
+		if value, ok := resolveJSONPointer(body.doc, tokens); ok {
+			return jsonValueAsString(value)
+		}
+	}
+
+	return ""
+}
+
+// decodeBody returns the generic JSON document of a body,
+// or nil if the body is neither a KvlistValue nor a JSON encoded string.
+func decodeBody(body *commonpb.AnyValue) any {
+	switch v := body.GetValue().(type) {
+	case *commonpb.AnyValue_KvlistValue, *commonpb.AnyValue_ArrayValue:
+		return jsonValue(body)
+	case *commonpb.AnyValue_StringValue:
+		s := strings.TrimSpace(v.StringValue)
+		if s == "" || (s[0] != '{' && s[0] != '[') {
+			return nil
+		}
+
+		dec := json.NewDecoder(strings.NewReader(s))
+		dec.UseNumber()
+
+		var doc any
+		if err := dec.Decode(&doc); err != nil {
+			return nil
+		}
+		return doc
+	default:
+		return nil
+	}
+}
+
+// parseJSONPointer splits a JSON pointer into its unescaped reference tokens.
+func parseJSONPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+
+	if pointer[0] != '/' {
+		return nil, fmt.Errorf("pointer %q must start with /", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+func resolveJSONPointer(doc any, tokens []string) (any, bool) {
+	if doc == nil {
+		return nil, false
+	}
+
+	for _, token := range tokens {
+		switch v := doc.(type) {
+		case map[string]any:
+			next, ok := v[token]
+			if !ok {
+				return nil, false
+			}
+			doc = next
+		case []any:
+			index, err := strconv.Atoi(token)
+			if err != nil || index < 0 || index >= len(v) {
+				return nil, false
+			}
+			doc = v[index]
+		default:
+			return nil, false
+		}
+	}
+
+	return doc, doc != nil
+}
+
+// jsonValueAsString formats a resolved JSON value,
+// rendering strings as is and everything else as canonical JSON.
+func jsonValueAsString(value any) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+
+	s, err := encodeJSON(value)
+	if err != nil {
+		return ""
+	}
+	return s
+}
@@ -1,51 +1,107 @@
 package otel
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"math"
+	"strconv"
+	"strings"
 
 	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
 	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
 	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+
+	"github.com/miguelhrocha/otel-collector/config"
 )
 
 const unknownValue = "unknown"
 
 // AttributeExtractor extracts the configured
-// AttributeKey from OTLP log records
+// AttributeKeys from OTLP log records
 type AttributeExtractor struct {
-	attributeKey string
+	attributeKeys []string
+	body          *bodyExtractor
+	normalizer    *normalizer
 }
 
 // NewAttributeExtractor creates a new AttributeExtractor
-// with the given attribute key.
-func NewAttributeExtractor(attributeKey string) *AttributeExtractor {
-	return &AttributeExtractor{attributeKey}
+// with the attribute keys, body extraction and normalization rules from the config.
+//
+// It returns an error if the BodyRegex, any of the BodyJSONPointers or the
+// normalization rules are invalid, or if the NormalizeMappingFile cannot be read.
+func NewAttributeExtractor(cfg config.Config) (*AttributeExtractor, error) {
+	body, err := newBodyExtractor(cfg.AttributeKeys, cfg.BodyRegex, cfg.BodyJSONPointers)
+	if err != nil {
+		return nil, err
+	}
+
+	normalizer, err := newNormalizer(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AttributeExtractor{
+		attributeKeys: cfg.AttributeKeys,
+		body:          body,
+		normalizer:    normalizer,
+	}, nil
 }
 
-// Extract retrieves the application-configured attributer value from the log record.
+// Extract retrieves the application-configured attribute values from the log record.
 //
-// The hierarchy for attribute extraction is as follows:
+// It returns one value per configured attribute key, in the same order.
+// Keys may be paths into nested map and array values, see LookupAttribute.
+// Each key is looked up independently, following this hierarchy:
 // 1. Log Record Attributes
 // 2. Instrumentation Scope Attributes
 // 3. Resource Attributes
-// If the attribute is not found, an empty string is returned.
+// 4. The BodyRegex capture group for the key, if configured
+// 5. The BodyJSONPointers pointer for the key, if configured
+// If an attribute is not found, "unknown" is returned in its place.
+// The values found are then normalized, see config.NormalizeTrim.
 func (extractor *AttributeExtractor) Extract(
 	logRecord *logspb.LogRecord,
 	scope *commonpb.InstrumentationScope,
 	resource *resourcepb.Resource,
+) []string {
+	body := &parsedBody{body: logRecord.GetBody()}
+
+	values := make([]string, len(extractor.attributeKeys))
+	for i, key := range extractor.attributeKeys {
+		values[i] = extractor.extractKey(key, logRecord, scope, resource, body)
+		if extractor.normalizer != nil && values[i] != unknownValue {
+			values[i] = extractor.normalizer.normalize(key, values[i])
+		}
+	}
+	return values
+}
+
+func (extractor *AttributeExtractor) extractKey(
+	key string,
+	logRecord *logspb.LogRecord,
+	scope *commonpb.InstrumentationScope,
+	resource *resourcepb.Resource,
+	body *parsedBody,
 ) string {
-	if value := extractor.findInAttributes(logRecord.GetAttributes()); value != "" {
+	if value := findInAttributes(key, logRecord.GetAttributes()); value != "" {
 		return value
 	}
 
 	if scope != nil {
-		if value := extractor.findInAttributes(scope.GetAttributes()); value != "" {
+		if value := findInAttributes(key, scope.GetAttributes()); value != "" {
 			return value
 		}
 	}
 
 	if resource != nil {
-		if value := extractor.findInAttributes(resource.GetAttributes()); value != "" {
+		if value := findInAttributes(key, resource.GetAttributes()); value != "" {
+			return value
+		}
+	}
+
+	if extractor.body.enabled() {
+		if value := extractor.body.extract(key, body); value != "" {
 			return value
 		}
 	}
@@ -53,15 +109,98 @@ func (extractor *AttributeExtractor) Extract(
 	return unknownValue
 }
 
-func (extractor *AttributeExtractor) findInAttributes(attributes []*commonpb.KeyValue) string {
-	for _, attr := range attributes {
-		if attr.GetKey() == extractor.attributeKey {
-			return AnyValueAsString(attr.GetValue())
-		}
+func findInAttributes(key string, attributes []*commonpb.KeyValue) string {
+	if value, ok := LookupAttribute(attributes, key); ok {
+		return AnyValueAsString(value)
 	}
 	return ""
 }
 
+// ExtractNumber retrieves the numeric value of an attribute from the log record.
+//
+// The key is looked up in the log record, scope and resource attributes, in that
+// order, and may be a path into nested values, see LookupAttribute.
+// It returns false if the attribute is missing or not a number, see AnyValueAsNumber.
+func ExtractNumber(
+	key string,
+	logRecord *logspb.LogRecord,
+	scope *commonpb.InstrumentationScope,
+	resource *resourcepb.Resource,
+) (float64, bool) {
+	if value, ok := lookupRecordAttribute(key, logRecord, scope, resource); ok {
+		return AnyValueAsNumber(value)
+	}
+	return 0, false
+}
+
+// ExtractString retrieves the value of an attribute from the log record as a string.
+//
+// The key is looked up like in ExtractNumber, and the value is rendered with AnyValueAsString.
+// It returns false if the attribute is missing.
+func ExtractString(
+	key string,
+	logRecord *logspb.LogRecord,
+	scope *commonpb.InstrumentationScope,
+	resource *resourcepb.Resource,
+) (string, bool) {
+	if value, ok := lookupRecordAttribute(key, logRecord, scope, resource); ok {
+		return AnyValueAsString(value), true
+	}
+	return "", false
+}
+
+// lookupRecordAttribute returns the first value of the attribute found
+// in the log record, scope and resource attributes.
+func lookupRecordAttribute(
+	key string,
+	logRecord *logspb.LogRecord,
+	scope *commonpb.InstrumentationScope,
+	resource *resourcepb.Resource,
+) (*commonpb.AnyValue, bool) {
+	for _, attributes := range [][]*commonpb.KeyValue{
+		logRecord.GetAttributes(),
+		scope.GetAttributes(),
+		resource.GetAttributes(),
+	} {
+		if value, ok := LookupAttribute(attributes, key); ok {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+// AnyValueAsNumber returns the numeric value of an OTLP AnyValue.
+//
+// Int and double values are returned as is, and string values are parsed as numbers.
+// It returns false for any other value, and for values that are not finite.
+func AnyValueAsNumber(value *commonpb.AnyValue) (float64, bool) {
+	var n float64
+
+	switch v := value.GetValue().(type) {
+	case *commonpb.AnyValue_IntValue:
+		n = float64(v.IntValue)
+	case *commonpb.AnyValue_DoubleValue:
+		n = v.DoubleValue
+	case *commonpb.AnyValue_StringValue:
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(v.StringValue), 64)
+		if err != nil {
+			return 0, false
+		}
+		n = parsed
+	default:
+		return 0, false
+	}
+
+	if math.IsNaN(n) || math.IsInf(n, 0) {
+		return 0, false
+	}
+	return n, true
+}
+
+// AnyValueAsString returns the string representation of an OTLP AnyValue.
+//
+// Scalar values are formatted as is, while KvlistValue and ArrayValue
+// are rendered as canonical JSON, see AnyValueAsJSON.
 func AnyValueAsString(value *commonpb.AnyValue) string {
 	if value == nil {
 		return unknownValue
@@ -81,8 +220,75 @@ func AnyValueAsString(value *commonpb.AnyValue) string {
 		return fmt.Sprintf("%t", v.BoolValue)
 	case *commonpb.AnyValue_BytesValue:
 		return string(v.BytesValue)
+	case *commonpb.AnyValue_KvlistValue, *commonpb.AnyValue_ArrayValue:
+		return AnyValueAsJSON(value)
 	default:
-		// For complex types (arrays, maps), return unknown
 		return unknownValue
 	}
 }
+
+// AnyValueAsJSON renders an OTLP AnyValue as canonical JSON.
+//
+// Map keys are sorted and no insignificant whitespace is emitted, so equal
+// values always render to the same string regardless of the order their
+// entries were sent in. When a map holds the same key more than once, the
+// last entry wins. Bytes are rendered as base64 strings, and non-finite
+// doubles as strings.
+func AnyValueAsJSON(value *commonpb.AnyValue) string {
+	s, err := encodeJSON(jsonValue(value))
+	if err != nil {
+		return unknownValue
+	}
+	return s
+}
+
+// encodeJSON encodes v as compact JSON without HTML escaping.
+func encodeJSON(v any) (string, error) {
+	var b bytes.Buffer
+
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return "", err
+	}
+
+	// Encode always terminates the value with a newline.
+	return string(bytes.TrimSuffix(b.Bytes(), []byte{'\n'})), nil
+}
+
+// jsonValue converts an AnyValue to the Go value encoding/json renders canonically.
+//
+// encoding/json sorts map keys, which is what makes the output canonical.
+func jsonValue(value *commonpb.AnyValue) any {
+	switch v := value.GetValue().(type) {
+	case *commonpb.AnyValue_StringValue:
+		return v.StringValue
+	case *commonpb.AnyValue_IntValue:
+		return v.IntValue
+	case *commonpb.AnyValue_DoubleValue:
+		if math.IsNaN(v.DoubleValue) || math.IsInf(v.DoubleValue, 0) {
+			return fmt.Sprintf("%g", v.DoubleValue)
+		}
+		return v.DoubleValue
+	case *commonpb.AnyValue_BoolValue:
+		return v.BoolValue
+	case *commonpb.AnyValue_BytesValue:
+		return v.BytesValue
+	case *commonpb.AnyValue_ArrayValue:
+		values := v.ArrayValue.GetValues()
+		out := make([]any, len(values))
+		for i, elem := range values {
+			out[i] = jsonValue(elem)
+		}
+		return out
+	case *commonpb.AnyValue_KvlistValue:
+		values := v.KvlistValue.GetValues()
+		out := make(map[string]any, len(values))
+		for _, kv := range values {
+			out[kv.GetKey()] = jsonValue(kv.GetValue())
+		}
+		return out
+	default:
+		return nil
+	}
+}
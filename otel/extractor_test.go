@@ -1,10 +1,14 @@
 package otel_test
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	"github.com/miguelhrocha/otel-collector/config"
 	"github.com/miguelhrocha/otel-collector/otel"
 	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
 	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
@@ -12,7 +16,7 @@ import (
 )
 
 func TestExtractor(t *testing.T) {
-	extractor := otel.NewAttributeExtractor("foo")
+	extractor := newExtractor(t, config.Config{AttributeKeys: []string{"foo"}})
 
 	t.Run("extracts attribute from log record", func(t *testing.T) {
 		logRecord := &logspb.LogRecord{
@@ -27,7 +31,7 @@ func TestExtractor(t *testing.T) {
 		}
 
 		result := extractor.Extract(logRecord, nil, nil)
-		assert.Equal(t, "bar", result)
+		assert.Equal(t, []string{"bar"}, result)
 	})
 
 	t.Run("extracts attribute from scope", func(t *testing.T) {
@@ -44,7 +48,7 @@ func TestExtractor(t *testing.T) {
 		}
 
 		result := extractor.Extract(logRecord, scope, nil)
-		assert.Equal(t, "scope-value", result)
+		assert.Equal(t, []string{"scope-value"}, result)
 	})
 
 	t.Run("extracts attribut from resource", func(t *testing.T) {
@@ -61,7 +65,7 @@ func TestExtractor(t *testing.T) {
 		}
 
 		result := extractor.Extract(logRecord, nil, resource)
-		assert.Equal(t, "resource-value", result)
+		assert.Equal(t, []string{"resource-value"}, result)
 	})
 
 	t.Run("extracts respect log record > scope > resource priority", func(t *testing.T) {
@@ -100,7 +104,7 @@ func TestExtractor(t *testing.T) {
 
 		result := extractor.Extract(logRecord, scope, resource)
 
-		assert.Equal(t, "log-value", result)
+		assert.Equal(t, []string{"log-value"}, result)
 	})
 
 	t.Run("not found key returns unknown string", func(t *testing.T) {
@@ -116,7 +120,327 @@ func TestExtractor(t *testing.T) {
 		}
 
 		result := extractor.Extract(logRecord, nil, nil)
-		assert.Equal(t, "unknown", result)
+		assert.Equal(t, []string{"unknown"}, result)
 	})
 
+	t.Run("extracts each key independently", func(t *testing.T) {
+		extractor := newExtractor(t, config.Config{AttributeKeys: []string{"service.name", "http.route", "status"}})
+
+		logRecord := &logspb.LogRecord{
+			Attributes: []*commonpb.KeyValue{
+				{
+					Key: "http.route",
+					Value: &commonpb.AnyValue{
+						Value: &commonpb.AnyValue_StringValue{StringValue: "/checkout"},
+					},
+				},
+			},
+		}
+
+		resource := &resourcepb.Resource{
+			Attributes: []*commonpb.KeyValue{
+				{
+					Key: "service.name",
+					Value: &commonpb.AnyValue{
+						Value: &commonpb.AnyValue_StringValue{StringValue: "checkout"},
+					},
+				},
+				{
+					Key: "http.route",
+					Value: &commonpb.AnyValue{
+						Value: &commonpb.AnyValue_StringValue{StringValue: "resource-route"},
+					},
+				},
+			},
+		}
+
+		result := extractor.Extract(logRecord, nil, resource)
+		assert.Equal(t, []string{"checkout", "/checkout", "unknown"}, result)
+	})
+
+	t.Run("extracts nested kvlist and array values by path", func(t *testing.T) {
+		extractor := newExtractor(t, config.Config{AttributeKeys: []string{"http.request.headers.user-agent", "tags[1]", "http.request.headers.x-ids[0]"}})
+
+		logRecord := &logspb.LogRecord{
+			Attributes: []*commonpb.KeyValue{
+				{
+					Key: "http.request.headers",
+					Value: kvlistValue(
+						&commonpb.KeyValue{Key: "user-agent", Value: stringValue("curl/8.0")},
+						&commonpb.KeyValue{Key: "x-ids", Value: arrayValue(stringValue("a"), stringValue("b"))},
+					),
+				},
+				{
+					Key:   "tags",
+					Value: arrayValue(stringValue("blue"), stringValue("green")),
+				},
+			},
+		}
+
+		result := extractor.Extract(logRecord, nil, nil)
+		assert.Equal(t, []string{"curl/8.0", "green", "a"}, result)
+	})
+
+	t.Run("missing paths fall back to the next level", func(t *testing.T) {
+		extractor := newExtractor(t, config.Config{AttributeKeys: []string{"tags[5]"}})
+
+		logRecord := &logspb.LogRecord{
+			Attributes: []*commonpb.KeyValue{
+				{Key: "tags", Value: arrayValue(stringValue("blue"))},
+			},
+		}
+		resource := &resourcepb.Resource{
+			Attributes: []*commonpb.KeyValue{
+				{Key: "tags", Value: arrayValue(stringValue("a"), stringValue("b"), stringValue("c"), stringValue("d"), stringValue("e"), stringValue("f"))},
+			},
+		}
+
+		result := extractor.Extract(logRecord, nil, resource)
+		assert.Equal(t, []string{"f"}, result)
+	})
+
+	t.Run("renders complex values as canonical JSON", func(t *testing.T) {
+		extractor := newExtractor(t, config.Config{AttributeKeys: []string{"headers"}})
+
+		logRecord := &logspb.LogRecord{
+			Attributes: []*commonpb.KeyValue{
+				{
+					Key: "headers",
+					Value: kvlistValue(
+						&commonpb.KeyValue{Key: "b", Value: arrayValue(stringValue("<x>"), &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: 1}})},
+						&commonpb.KeyValue{Key: "a", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: true}}},
+					),
+				},
+			},
+		}
+
+		result := extractor.Extract(logRecord, nil, nil)
+		assert.Equal(t, []string{`{"a":true,"b":["<x>",1]}`}, result)
+	})
+}
+
+func TestExtractorBody(t *testing.T) {
+	t.Run("extracts named regex groups from the body", func(t *testing.T) {
+		extractor := newExtractor(t, config.Config{
+			AttributeKeys: []string{"service.name", "status"},
+			BodyRegex:     `service=(?P<service_name>\S+) status=(?P<status>\d+)`,
+		})
+
+		logRecord := &logspb.LogRecord{
+			Body: stringValue("request done service=checkout status=200"),
+		}
+
+		result := extractor.Extract(logRecord, nil, nil)
+		assert.Equal(t, []string{"checkout", "200"}, result)
+	})
+
+	t.Run("resolves JSON pointers into JSON string bodies", func(t *testing.T) {
+		extractor := newExtractor(t, config.Config{
+			AttributeKeys:    []string{"service.name", "status"},
+			BodyJSONPointers: map[string]string{"service.name": "/service/name", "status": "/http/status"},
+		})
+
+		logRecord := &logspb.LogRecord{
+			Body: stringValue(`{"service": {"name": "checkout"}, "http": {"status": 200}}`),
+		}
+
+		result := extractor.Extract(logRecord, nil, nil)
+		assert.Equal(t, []string{"checkout", "200"}, result)
+	})
+
+	t.Run("resolves JSON pointers into kvlist bodies", func(t *testing.T) {
+		extractor := newExtractor(t, config.Config{
+			AttributeKeys:    []string{"user"},
+			BodyJSONPointers: map[string]string{"user": "/users/1"},
+		})
+
+		logRecord := &logspb.LogRecord{
+			Body: kvlistValue(&commonpb.KeyValue{Key: "users", Value: arrayValue(stringValue("alice"), stringValue("bob"))}),
+		}
+
+		result := extractor.Extract(logRecord, nil, nil)
+		assert.Equal(t, []string{"bob"}, result)
+	})
+
+	t.Run("attributes take precedence over the body", func(t *testing.T) {
+		extractor := newExtractor(t, config.Config{
+			AttributeKeys: []string{"foo"},
+			BodyRegex:     `foo=(?P<foo>\w+)`,
+		})
+
+		logRecord := &logspb.LogRecord{
+			Attributes: []*commonpb.KeyValue{{Key: "foo", Value: stringValue("attribute")}},
+			Body:       stringValue("foo=body"),
+		}
+
+		result := extractor.Extract(logRecord, nil, nil)
+		assert.Equal(t, []string{"attribute"}, result)
+	})
+
+	t.Run("rejects invalid regexes", func(t *testing.T) {
+		_, err := otel.NewAttributeExtractor(config.Config{
+			AttributeKeys: []string{"foo"},
+			BodyRegex:     `(?P<foo>`,
+		})
+		assert.Error(t, err)
+	})
+}
+
+func TestExtractorNormalize(t *testing.T) {
+	logRecord := func(service string) *logspb.LogRecord {
+		return &logspb.LogRecord{Attributes: []*commonpb.KeyValue{{Key: "service.name", Value: stringValue(service)}}}
+	}
+
+	t.Run("trims and lowercases values", func(t *testing.T) {
+		extractor := newExtractor(t, config.Config{
+			AttributeKeys:      []string{"service.name"},
+			NormalizeTrim:      true,
+			NormalizeLowercase: true,
+		})
+
+		for _, service := range []string{"Checkout", "checkout ", " CHECKOUT"} {
+			assert.Equal(t, []string{"checkout"}, extractor.Extract(logRecord(service), nil, nil), service)
+		}
+	})
+
+	t.Run("applies regex replacements in order", func(t *testing.T) {
+		extractor := newExtractor(t, config.Config{
+			AttributeKeys:    []string{"service.name"},
+			NormalizeReplace: []string{`-svc$=>`, `^(\w+)-v\d+$=>$1`},
+		})
+
+		assert.Equal(t, []string{"checkout"}, extractor.Extract(logRecord("checkout-svc"), nil, nil))
+		assert.Equal(t, []string{"checkout"}, extractor.Extract(logRecord("checkout-v2-svc"), nil, nil))
+	})
+
+	t.Run("maps values per key from a file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "mappings.json")
+		require.NoError(t, os.WriteFile(path, []byte(`{"service.name": {"co": "checkout"}, "status": {"co": "colombia"}}`), 0o600))
+
+		extractor := newExtractor(t, config.Config{
+			AttributeKeys:        []string{"service.name", "status"},
+			NormalizeLowercase:   true,
+			NormalizeMappingFile: path,
+		})
+
+		logRecord := logRecord("CO")
+		logRecord.Attributes = append(logRecord.Attributes, &commonpb.KeyValue{Key: "status", Value: stringValue("ok")})
+		assert.Equal(t, []string{"checkout", "ok"}, extractor.Extract(logRecord, nil, nil))
+	})
+
+	t.Run("truncates long values by character", func(t *testing.T) {
+		extractor := newExtractor(t, config.Config{
+			AttributeKeys:      []string{"service.name"},
+			NormalizeMaxLength: 4,
+		})
+
+		assert.Equal(t, []string{"chec"}, extractor.Extract(logRecord("checkout"), nil, nil))
+		assert.Equal(t, []string{"café"}, extractor.Extract(logRecord("cafés"), nil, nil))
+	})
+
+	t.Run("leaves missing and emptied values unknown", func(t *testing.T) {
+		extractor := newExtractor(t, config.Config{
+			AttributeKeys:      []string{"service.name", "status"},
+			NormalizeTrim:      true,
+			NormalizeLowercase: true,
+		})
+
+		assert.Equal(t, []string{"unknown", "unknown"}, extractor.Extract(logRecord("  "), nil, nil))
+	})
+
+	t.Run("rejects invalid rules", func(t *testing.T) {
+		for _, cfg := range []config.Config{
+			{NormalizeReplace: []string{"-svc$"}},
+			{NormalizeReplace: []string{"(=>x"}},
+			{NormalizeMappingFile: filepath.Join(t.TempDir(), "missing.json")},
+			{NormalizeMaxLength: -1},
+		} {
+			cfg.AttributeKeys = []string{"service.name"}
+			_, err := otel.NewAttributeExtractor(cfg)
+			assert.Error(t, err)
+		}
+	})
+}
+
+func TestExtractNumber(t *testing.T) {
+	intValue := &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: 512}}
+	doubleValue := &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: 12.5}}
+
+	t.Run("extracts int, double and numeric string values", func(t *testing.T) {
+		logRecord := &logspb.LogRecord{
+			Attributes: []*commonpb.KeyValue{
+				{Key: "http.response.size", Value: intValue},
+				{Key: "duration_ms", Value: doubleValue},
+				{Key: "retries", Value: stringValue(" 3 ")},
+			},
+		}
+
+		for key, expected := range map[string]float64{"http.response.size": 512, "duration_ms": 12.5, "retries": 3} {
+			v, ok := otel.ExtractNumber(key, logRecord, nil, nil)
+			assert.True(t, ok, key)
+			assert.Equal(t, expected, v, key)
+		}
+	})
+
+	t.Run("falls back to scope and resource attributes", func(t *testing.T) {
+		resource := &resourcepb.Resource{Attributes: []*commonpb.KeyValue{{Key: "duration_ms", Value: doubleValue}}}
+
+		v, ok := otel.ExtractNumber("duration_ms", &logspb.LogRecord{}, &commonpb.InstrumentationScope{}, resource)
+		assert.True(t, ok)
+		assert.Equal(t, 12.5, v)
+	})
+
+	t.Run("rejects missing and non-numeric values", func(t *testing.T) {
+		logRecord := &logspb.LogRecord{
+			Attributes: []*commonpb.KeyValue{
+				{Key: "status", Value: stringValue("ok")},
+				{Key: "ratio", Value: stringValue("NaN")},
+			},
+		}
+
+		for _, key := range []string{"status", "ratio", "missing"} {
+			_, ok := otel.ExtractNumber(key, logRecord, nil, nil)
+			assert.False(t, ok, key)
+		}
+	})
+}
+
+func TestExtractString(t *testing.T) {
+	resource := &resourcepb.Resource{Attributes: []*commonpb.KeyValue{{Key: "user.id", Value: stringValue("u-1")}}}
+	logRecord := &logspb.LogRecord{
+		Attributes: []*commonpb.KeyValue{
+			{Key: "user.id", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: 42}}},
+		},
+	}
+
+	v, ok := otel.ExtractString("user.id", logRecord, nil, resource)
+	assert.True(t, ok)
+	assert.Equal(t, "42", v, "Log record attributes take precedence")
+
+	v, ok = otel.ExtractString("user.id", &logspb.LogRecord{}, nil, resource)
+	assert.True(t, ok)
+	assert.Equal(t, "u-1", v)
+
+	_, ok = otel.ExtractString("missing", logRecord, nil, resource)
+	assert.False(t, ok)
+}
+
+func newExtractor(t *testing.T, cfg config.Config) *otel.AttributeExtractor {
+	t.Helper()
+
+	extractor, err := otel.NewAttributeExtractor(cfg)
+	require.NoError(t, err)
+	return extractor
+}
+
+func stringValue(s string) *commonpb.AnyValue {
+	return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: s}}
+}
+
+func arrayValue(values ...*commonpb.AnyValue) *commonpb.AnyValue {
+	return &commonpb.AnyValue{Value: &commonpb.AnyValue_ArrayValue{ArrayValue: &commonpb.ArrayValue{Values: values}}}
+}
+
+func kvlistValue(values ...*commonpb.KeyValue) *commonpb.AnyValue {
+	return &commonpb.AnyValue{Value: &commonpb.AnyValue_KvlistValue{KvlistValue: &commonpb.KeyValueList{Values: values}}}
 }
@@ -0,0 +1,134 @@
+package otel
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/miguelhrocha/otel-collector/config"
+)
+
+// replacementSeparator separates the regex from the replacement in config.NormalizeReplace.
+const replacementSeparator = "=>"
+
+// normalizer rewrites extracted attribute values so that spellings of the same
+// value are aggregated together, see config.NormalizeTrim.
+type normalizer struct {
+	trim         bool
+	lowercase    bool
+	replacements []replacement
+	// mappings holds the canonical values of each attribute key, by value.
+	mappings  map[string]map[string]string
+	maxLength int
+}
+
+type replacement struct {
+	re   *regexp.Regexp
+	with string
+}
+
+// newNormalizer creates a normalizer from the config's Normalize fields.
+//
+// It returns nil if no normalization is configured, and an error if a replacement
+// or the mapping file is invalid.
+func newNormalizer(cfg config.Config) (*normalizer, error) {
+	if cfg.NormalizeMaxLength < 0 {
+		return nil, fmt.Errorf("normalize max length must not be negative, got %d", cfg.NormalizeMaxLength)
+	}
+
+	n := &normalizer{
+		trim:      cfg.NormalizeTrim,
+		lowercase: cfg.NormalizeLowercase,
+		maxLength: cfg.NormalizeMaxLength,
+	}
+
+	for _, text := range cfg.NormalizeReplace {
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+
+		pattern, with, ok := strings.Cut(text, replacementSeparator)
+		if !ok {
+			return nil, fmt.Errorf("normalize replacement %q: missing %q", text, replacementSeparator)
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("normalize replacement %q: %w", text, err)
+		}
+		n.replacements = append(n.replacements, replacement{re: re, with: with})
+	}
+
+	if cfg.NormalizeMappingFile != "" {
+		mappings, err := loadMappings(cfg.NormalizeMappingFile)
+		if err != nil {
+			return nil, err
+		}
+		n.mappings = mappings
+	}
+
+	if !n.trim && !n.lowercase && len(n.replacements) == 0 && len(n.mappings) == 0 && n.maxLength == 0 {
+		return nil, nil
+	}
+	return n, nil
+}
+
+// loadMappings reads a value mapping file, see config.NormalizeMappingFile.
+func loadMappings(path string) (map[string]map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading normalize mapping file: %w", err)
+	}
+
+	var mappings map[string]map[string]string
+	if err := json.Unmarshal(data, &mappings); err != nil {
+		return nil, fmt.Errorf("parsing normalize mapping file %s: %w", path, err)
+	}
+	if mappings == nil {
+		return nil, errors.New("normalize mapping file must contain a JSON object")
+	}
+	return mappings, nil
+}
+
+// normalize returns the normalized value of an attribute key.
+//
+// Values normalized to an empty string are reported as unknown, like missing attributes.
+func (n *normalizer) normalize(key, value string) string {
+	if n.trim {
+		value = strings.TrimSpace(value)
+	}
+	if n.lowercase {
+		value = strings.ToLower(value)
+	}
+	for _, r := range n.replacements {
+		value = r.re.ReplaceAllString(value, r.with)
+	}
+	if canonical, ok := n.mappings[key][value]; ok {
+		value = canonical
+	}
+	if n.maxLength > 0 {
+		value = truncate(value, n.maxLength)
+	}
+
+	if value == "" {
+		return unknownValue
+	}
+	return value
+}
+
+// truncate returns the first maxChars characters of s, without splitting multi-byte characters.
+func truncate(s string, maxChars int) string {
+	if len(s) <= maxChars {
+		return s
+	}
+
+	i := 0
+	for n := 0; n < maxChars && i < len(s); n++ {
+		_, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+	}
+	return s[:i]
+}
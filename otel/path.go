@@ -0,0 +1,81 @@
+package otel
+
+import (
+	"strconv"
+	"strings"
+
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+)
+
+// LookupAttribute finds the value at path in the given attributes.
+//
+// A path is an attribute key optionally followed by selectors that walk into
+// complex values:
+//   - ".key" selects an entry of a KvlistValue, e.g. "http.request.headers.user-agent"
+//   - "[n]" selects the nth element of an ArrayValue, e.g. "tags[0]"
+//
+// Since attribute keys commonly contain dots themselves, a path is matched against
+// the longest attribute key it starts with. An attribute whose key equals the
+// whole path always takes precedence, and the same rule applies at every
+// nesting level of a KvlistValue.
+func LookupAttribute(attributes []*commonpb.KeyValue, path string) (*commonpb.AnyValue, bool) {
+	var (
+		best     *commonpb.KeyValue
+		bestRest string
+	)
+
+	for _, attr := range attributes {
+		key := attr.GetKey()
+		if key == path {
+			return attr.GetValue(), true
+		}
+
+		if len(key) <= len(best.GetKey()) || !strings.HasPrefix(path, key) {
+			continue
+		}
+
+		if rest := path[len(key):]; rest[0] == '.' || rest[0] == '[' {
+			best, bestRest = attr, rest
+		}
+	}
+
+	if best == nil {
+		return nil, false
+	}
+
+	return walkValue(best.GetValue(), bestRest)
+}
+
+// walkValue applies the selectors in path to value.
+func walkValue(value *commonpb.AnyValue, path string) (*commonpb.AnyValue, bool) {
+	if path == "" {
+		return value, true
+	}
+
+	switch path[0] {
+	case '.':
+		kvlist := value.GetKvlistValue()
+		if kvlist == nil {
+			return nil, false
+		}
+		return LookupAttribute(kvlist.GetValues(), path[1:])
+	case '[':
+		end := strings.IndexByte(path, ']')
+		if end < 0 {
+			return nil, false
+		}
+
+		index, err := strconv.Atoi(path[1:end])
+		if err != nil {
+			return nil, false
+		}
+
+		values := value.GetArrayValue().GetValues()
+		if index < 0 || index >= len(values) {
+			return nil, false
+		}
+		return walkValue(values[index], path[end+1:])
+	default:
+		return nil, false
+	}
+}
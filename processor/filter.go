@@ -0,0 +1,246 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+
+	"github.com/miguelhrocha/otel-collector/config"
+	"github.com/miguelhrocha/otel-collector/ingestor"
+	"github.com/miguelhrocha/otel-collector/metrics"
+	"github.com/miguelhrocha/otel-collector/otel"
+)
+
+// Prefixes and fields of filter rules, see config.FilterInclude.
+const (
+	filterFieldSeverity  = "severity"
+	filterFieldScopeName = "scope.name"
+	filterFieldBody      = "body"
+
+	filterAttributePrefix = "attr."
+	filterResourcePrefix  = "resource."
+)
+
+// Operators of filter rules. A rule without an operator checks that an attribute exists.
+var filterOperators = []string{"==", "=~", ">=", "*="}
+
+// Filter drops log records according to include and exclude rules.
+//
+// A record is kept if it matches every include rule and none of the exclude rules.
+// Dropped records are counted in metrics.FilteredRecords by the rule that dropped them.
+//
+// Use NewFilter to create a new Filter.
+type Filter struct {
+	include []filterRule
+	exclude []filterRule
+}
+
+// filterRule is a single parsed rule.
+type filterRule struct {
+	// text is the rule as configured, used to attribute dropped records.
+	text string
+
+	match func(logRecord *logspb.LogRecord, scope *commonpb.InstrumentationScope, resource *resourcepb.Resource) bool
+}
+
+// NewFilter creates a Filter with the rules of the config's FilterInclude and FilterExclude fields.
+//
+// It returns an error if a rule cannot be parsed.
+func NewFilter(cfg config.Config) (*Filter, error) {
+	include, err := parseFilterRules(cfg.FilterInclude)
+	if err != nil {
+		return nil, fmt.Errorf("parsing include filter: %w", err)
+	}
+
+	exclude, err := parseFilterRules(cfg.FilterExclude)
+	if err != nil {
+		return nil, fmt.Errorf("parsing exclude filter: %w", err)
+	}
+
+	return &Filter{include: include, exclude: exclude}, nil
+}
+
+// Keep reports whether a log record passes the filter, counting it as dropped otherwise.
+func (f *Filter) Keep(
+	ctx context.Context,
+	logRecord *logspb.LogRecord,
+	scope *commonpb.InstrumentationScope,
+	resource *resourcepb.Resource,
+) bool {
+	for _, rule := range f.include {
+		if !rule.match(logRecord, scope, resource) {
+			f.drop(ctx, "include", rule)
+			return false
+		}
+	}
+
+	for _, rule := range f.exclude {
+		if rule.match(logRecord, scope, resource) {
+			f.drop(ctx, "exclude", rule)
+			return false
+		}
+	}
+
+	return true
+}
+
+// Process drops the entries rejected by the filter.
+func (f *Filter) Process(ctx context.Context, entries []Entry) []Entry {
+	return slices.DeleteFunc(entries, func(e Entry) bool {
+		return !f.Keep(ctx, e.Record, e.Scope, e.Resource)
+	})
+}
+
+func (f *Filter) drop(ctx context.Context, list string, rule filterRule) {
+	metrics.FilteredRecords.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("list", list),
+		attribute.String("rule", rule.text),
+	))
+}
+
+func parseFilterRules(texts []string) ([]filterRule, error) {
+	rules := make([]filterRule, 0, len(texts))
+	for _, text := range texts {
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+
+		rule, err := parseFilterRule(text)
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: %w", text, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func parseFilterRule(text string) (filterRule, error) {
+	field, op, value := text, "", ""
+	if i, o := indexOperator(text); i >= 0 {
+		field, op, value = text[:i], o, text[i+len(o):]
+	}
+
+	rule := filterRule{text: text}
+
+	switch {
+	case field == filterFieldSeverity:
+		if op != ">=" {
+			return rule, errors.New("severity only supports >=")
+		}
+		minimum, err := parseSeverity(value)
+		if err != nil {
+			return rule, err
+		}
+		rule.match = func(logRecord *logspb.LogRecord, _ *commonpb.InstrumentationScope, _ *resourcepb.Resource) bool {
+			return int32(logRecord.GetSeverityNumber()) >= minimum
+		}
+
+	case field == filterFieldBody:
+		if op != "*=" {
+			return rule, errors.New("body only supports *=")
+		}
+		rule.match = func(logRecord *logspb.LogRecord, _ *commonpb.InstrumentationScope, _ *resourcepb.Resource) bool {
+			return logRecord.GetBody() != nil && strings.Contains(otel.AnyValueAsString(logRecord.GetBody()), value)
+		}
+
+	case field == filterFieldScopeName:
+		if op == "" {
+			return rule, errors.New("scope.name requires == or =~")
+		}
+		matches, err := valueMatcher(op, value)
+		if err != nil {
+			return rule, err
+		}
+		rule.match = func(_ *logspb.LogRecord, scope *commonpb.InstrumentationScope, _ *resourcepb.Resource) bool {
+			return matches(scope.GetName())
+		}
+
+	case strings.HasPrefix(field, filterAttributePrefix), strings.HasPrefix(field, filterResourcePrefix):
+		resource := strings.HasPrefix(field, filterResourcePrefix)
+		key := strings.TrimPrefix(field, filterAttributePrefix)
+		if resource {
+			key = strings.TrimPrefix(field, filterResourcePrefix)
+		}
+		if key == "" {
+			return rule, errors.New("missing attribute key")
+		}
+
+		matches := func(string) bool { return true }
+		if op != "" {
+			var err error
+			if matches, err = valueMatcher(op, value); err != nil {
+				return rule, err
+			}
+		}
+
+		rule.match = func(logRecord *logspb.LogRecord, _ *commonpb.InstrumentationScope, r *resourcepb.Resource) bool {
+			attributes := logRecord.GetAttributes()
+			if resource {
+				attributes = r.GetAttributes()
+			}
+
+			v, ok := otel.LookupAttribute(attributes, key)
+			return ok && matches(otel.AnyValueAsString(v))
+		}
+
+	default:
+		return rule, fmt.Errorf("unknown field %q", field)
+	}
+
+	return rule, nil
+}
+
+// indexOperator returns the position and text of the first operator in a rule,
+// or -1 if it has none.
+func indexOperator(text string) (int, string) {
+	index, op := -1, ""
+	for _, o := range filterOperators {
+		if i := strings.Index(text, o); i >= 0 && (index < 0 || i < index) {
+			index, op = i, o
+		}
+	}
+	return index, op
+}
+
+// valueMatcher returns a function matching string values with the == or =~ operator.
+func valueMatcher(op, value string) (func(string) bool, error) {
+	switch op {
+	case "==":
+		return func(s string) bool { return s == value }, nil
+	case "=~":
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return nil, err
+		}
+		return re.MatchString, nil
+	default:
+		return nil, fmt.Errorf("unsupported operator %q", op)
+	}
+}
+
+// parseSeverity parses a severity range name or number into the minimum severity number.
+func parseSeverity(value string) (int32, error) {
+	for s := ingestor.SeverityTrace; s <= ingestor.SeverityFatal; s++ {
+		if strings.EqualFold(value, s.String()) {
+			// Each range spans four severity numbers, starting at 1 for TRACE.
+			return int32(s-ingestor.SeverityTrace)*4 + 1, nil
+		}
+	}
+
+	n, err := strconv.ParseInt(value, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("unknown severity %q", value)
+	}
+	return int32(n), nil
+}
@@ -0,0 +1,72 @@
+package processor_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+
+	"github.com/miguelhrocha/otel-collector/config"
+	"github.com/miguelhrocha/otel-collector/metrics"
+	"github.com/miguelhrocha/otel-collector/processor"
+)
+
+func TestFilter(t *testing.T) {
+	require.NoError(t, metrics.InitMetrics(otel.Meter("test")))
+
+	resource := &resourcepb.Resource{Attributes: []*commonpb.KeyValue{{Key: "service.name", Value: stringValue("checkout-api")}}}
+	scope := &commonpb.InstrumentationScope{Name: "net/http"}
+	logRecord := &logspb.LogRecord{
+		SeverityNumber: logspb.SeverityNumber_SEVERITY_NUMBER_WARN,
+		Body:           stringValue("payment declined"),
+		Attributes: []*commonpb.KeyValue{
+			{Key: "http.route", Value: stringValue("/pay")},
+			{Key: "id", Value: stringValue("x")},
+			{Key: "resource.id", Value: stringValue("y")},
+		},
+	}
+
+	for _, tc := range []struct {
+		name             string
+		include, exclude []string
+		keep             bool
+	}{
+		{name: "keeps everything without rules", keep: true},
+		{name: "minimum severity by name", include: []string{"severity>=WARN"}, keep: true},
+		{name: "minimum severity by number", include: []string{"severity>=17"}, keep: false},
+		{name: "attribute exists", include: []string{"attr.http.route"}, keep: true},
+		{name: "missing attribute", include: []string{"attr.user.id"}, keep: false},
+		{name: "attribute equals", exclude: []string{"attr.http.route==/pay"}, keep: false},
+		{name: "attribute regex", include: []string{"attr.http.route=~^/(pay|cart)$"}, keep: true},
+		{name: "log attribute with a resource prefix", include: []string{"attr.resource.id==y"}, keep: true},
+		{name: "log attribute with a resource prefix is not stripped twice", include: []string{"attr.resource.id==x"}, keep: false},
+		{name: "resource attribute", include: []string{"resource.service.name=~^checkout"}, keep: true},
+		{name: "scope name", exclude: []string{"scope.name==net/http"}, keep: false},
+		{name: "body contains", exclude: []string{"body*=heartbeat"}, keep: true},
+		{name: "every include rule must match", include: []string{"severity>=INFO", "body*=refund"}, keep: false},
+		{name: "exclude wins over include", include: []string{"severity>=INFO"}, exclude: []string{"body*=declined"}, keep: false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			filter, err := processor.NewFilter(config.Config{FilterInclude: tc.include, FilterExclude: tc.exclude})
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.keep, filter.Keep(context.Background(), logRecord, scope, resource))
+		})
+	}
+
+	t.Run("rejects invalid rules", func(t *testing.T) {
+		for _, rule := range []string{"severity>=LOUD", "severity==WARN", "body==x", "scope.name", "attr.", "attr.x=~(", "trace_id==1"} {
+			_, err := processor.NewFilter(config.Config{FilterInclude: []string{rule}})
+			assert.Error(t, err, rule)
+		}
+	})
+}
+
+func stringValue(s string) *commonpb.AnyValue {
+	return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: s}}
+}
@@ -0,0 +1,94 @@
+// Package processor transforms and drops log records between the receivers and the ingestor.
+package processor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+
+	"github.com/miguelhrocha/otel-collector/config"
+)
+
+// Entry is a log record along with the scope and resource that emitted it.
+type Entry struct {
+	Resource *resourcepb.Resource
+	Scope    *commonpb.InstrumentationScope
+	Record   *logspb.LogRecord
+
+	// Weight is the number of log records the entry stands for,
+	// 1 unless sampling dropped similar records. See config.SamplingScaleCounts.
+	Weight float64
+}
+
+// Processor transforms a batch of entries, returning the entries to keep.
+//
+// Processors may modify the entries and their records in place, and may reuse
+// the batch's backing array for the returned entries.
+type Processor interface {
+	Process(ctx context.Context, entries []Entry) []Entry
+}
+
+// Pipeline is a chain of processors, each processing the entries kept by the previous one.
+type Pipeline []Processor
+
+// factories creates the processors that can be listed in config.Processors, by name.
+var factories = map[string]func(cfg config.Config) (Processor, error){
+	"filter": func(cfg config.Config) (Processor, error) { return NewFilter(cfg) },
+	"sample": func(cfg config.Config) (Processor, error) { return NewSampler(cfg) },
+	"redact": func(cfg config.Config) (Processor, error) { return NewRedactor(cfg) },
+}
+
+// NewPipeline creates a Pipeline with the processors listed in the config's Processors field, in order.
+//
+// It returns an error if a processor is unknown or its config is invalid.
+func NewPipeline(cfg config.Config) (Pipeline, error) {
+	pipeline := make(Pipeline, 0, len(cfg.Processors))
+	for _, name := range cfg.Processors {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		factory, ok := factories[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown processor %q", name)
+		}
+
+		p, err := factory(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("creating processor %q: %w", name, err)
+		}
+		pipeline = append(pipeline, p)
+	}
+	return pipeline, nil
+}
+
+// Process runs the entries through every processor of the pipeline.
+func (p Pipeline) Process(ctx context.Context, entries []Entry) []Entry {
+	for _, processor := range p {
+		if len(entries) == 0 {
+			break
+		}
+		entries = processor.Process(ctx, entries)
+	}
+	return entries
+}
+
+// Entries flattens the log records of an OTLP request into entries of weight 1.
+func Entries(resourceLogs []*logspb.ResourceLogs) []Entry {
+	var entries []Entry
+	for _, resourceLog := range resourceLogs {
+		resource := resourceLog.GetResource()
+		for _, scopeLog := range resourceLog.GetScopeLogs() {
+			scope := scopeLog.GetScope()
+			for _, logRecord := range scopeLog.GetLogRecords() {
+				entries = append(entries, Entry{Resource: resource, Scope: scope, Record: logRecord, Weight: 1})
+			}
+		}
+	}
+	return entries
+}
@@ -0,0 +1,90 @@
+package processor_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+
+	"github.com/miguelhrocha/otel-collector/config"
+	"github.com/miguelhrocha/otel-collector/metrics"
+	"github.com/miguelhrocha/otel-collector/processor"
+)
+
+func TestPipeline(t *testing.T) {
+	require.NoError(t, metrics.InitMetrics(otel.Meter("test")))
+
+	resourceLogs := []*logspb.ResourceLogs{
+		{ScopeLogs: []*logspb.ScopeLogs{
+			{LogRecords: []*logspb.LogRecord{
+				{SeverityNumber: logspb.SeverityNumber_SEVERITY_NUMBER_INFO, Body: stringValue("a")},
+				{SeverityNumber: logspb.SeverityNumber_SEVERITY_NUMBER_DEBUG, Body: stringValue("b")},
+			}},
+		}},
+		{ScopeLogs: []*logspb.ScopeLogs{
+			{LogRecords: []*logspb.LogRecord{
+				{SeverityNumber: logspb.SeverityNumber_SEVERITY_NUMBER_ERROR, Body: stringValue("c")},
+			}},
+		}},
+	}
+
+	t.Run("flattens requests into entries", func(t *testing.T) {
+		entries := processor.Entries(resourceLogs)
+		require.Len(t, entries, 3)
+		for _, e := range entries {
+			assert.Equal(t, 1.0, e.Weight)
+		}
+		assert.Equal(t, "c", entries[2].Record.GetBody().GetStringValue())
+	})
+
+	t.Run("runs processors in order", func(t *testing.T) {
+		pipeline, err := processor.NewPipeline(config.Config{
+			Processors:    []string{"filter", " sample"},
+			FilterInclude: []string{"severity>=INFO"},
+			SamplingRatio: 1,
+		})
+		require.NoError(t, err)
+		require.Len(t, pipeline, 2)
+
+		entries := pipeline.Process(context.Background(), processor.Entries(resourceLogs))
+		require.Len(t, entries, 2)
+		assert.Equal(t, "a", entries[0].Record.GetBody().GetStringValue())
+		assert.Equal(t, "c", entries[1].Record.GetBody().GetStringValue())
+	})
+
+	t.Run("scales weights by the sampling ratio", func(t *testing.T) {
+		pipeline, err := processor.NewPipeline(config.Config{
+			Processors:    []string{"sample"},
+			SamplingRatio: 0.5,
+		})
+		require.NoError(t, err)
+
+		entries := processor.Entries(resourceLogs)
+		for i := range entries {
+			entries[i].Record.TraceId = traceID(i)
+		}
+		for _, e := range pipeline.Process(context.Background(), entries) {
+			assert.Equal(t, 2.0, e.Weight)
+		}
+	})
+
+	t.Run("keeps every entry without processors", func(t *testing.T) {
+		pipeline, err := processor.NewPipeline(config.Config{})
+		require.NoError(t, err)
+
+		assert.Len(t, pipeline.Process(context.Background(), processor.Entries(resourceLogs)), 3)
+	})
+
+	t.Run("rejects unknown processors", func(t *testing.T) {
+		_, err := processor.NewPipeline(config.Config{Processors: []string{"filter", "uppercase"}})
+		assert.ErrorContains(t, err, `unknown processor "uppercase"`)
+	})
+
+	t.Run("rejects invalid processor configs", func(t *testing.T) {
+		_, err := processor.NewPipeline(config.Config{Processors: []string{"sample"}, SamplingRatio: 2})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,259 @@
+package processor
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/netip"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+
+	"github.com/miguelhrocha/otel-collector/config"
+	"github.com/miguelhrocha/otel-collector/metrics"
+)
+
+// Supported values of config.RedactMode.
+const (
+	RedactModeMask = "mask"
+	RedactModeHash = "hash"
+)
+
+const (
+	redactedMask = "[REDACTED]"
+	hashPrefix   = "sha256:"
+	// hashLength is the number of hex digits of the hash kept in redacted values.
+	hashLength = 16
+)
+
+// redactPatterns are the built-in patterns of config.RedactPatterns, by name.
+var redactPatterns = map[string]redactPattern{
+	"email": {
+		re: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`),
+	},
+	"credit_card": {
+		re:    regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`),
+		valid: func(s string, start, end int) bool { return luhn(s[start:end]) },
+	},
+	"ip": {
+		re: regexp.MustCompile(`(?i)\b(?:\d{1,3}\.){3}\d{1,3}\b|(?:[0-9a-f]{0,4}:){2,7}(?:[0-9a-f]{0,4}|(?:\d{1,3}\.){3}\d{1,3})`),
+		valid: func(s string, start, end int) bool {
+			if _, err := netip.ParseAddr(s[start:end]); err != nil {
+				return false
+			}
+			// Skip addresses glued to surrounding text, such as "Foo::bar".
+			before, _ := utf8.DecodeLastRuneInString(s[:start])
+			after, _ := utf8.DecodeRuneInString(s[end:])
+			return !isAddressRune(before) && !isAddressRune(after)
+		},
+	},
+}
+
+// Redactor masks or hashes sensitive values, such as email addresses, in the
+// bodies and string attribute values of log records, and in the attributes of
+// their scope and resource.
+//
+// Redacted values are counted in metrics.Redactions by pattern.
+//
+// Use NewRedactor to create a new Redactor.
+type Redactor struct {
+	patterns []redactPattern
+	hash     bool
+	salt     string
+}
+
+// redactPattern is a pattern whose matches are redacted.
+type redactPattern struct {
+	// name is the built-in pattern name or the custom regex, used to attribute redactions.
+	name string
+	re   *regexp.Regexp
+	// valid, if set, reports whether the match s[start:end] is really sensitive.
+	valid func(s string, start, end int) bool
+}
+
+// redactMatch is the position of a match to redact, and the index of its pattern.
+type redactMatch struct {
+	start, end int
+	pattern    int
+}
+
+// NewRedactor creates a Redactor with the patterns and mode of the config's Redact fields.
+//
+// It returns an error if a pattern or the mode is unknown, a regex is invalid,
+// or the hash mode has no salt.
+func NewRedactor(cfg config.Config) (*Redactor, error) {
+	r := &Redactor{salt: cfg.RedactHashSalt}
+
+	switch cfg.RedactMode {
+	case RedactModeMask, "":
+	case RedactModeHash:
+		if cfg.RedactHashSalt == "" {
+			return nil, errors.New("redact hash salt is required in the hash mode")
+		}
+		r.hash = true
+	default:
+		return nil, fmt.Errorf("unknown redact mode %q", cfg.RedactMode)
+	}
+
+	for _, name := range cfg.RedactPatterns {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		pattern, ok := redactPatterns[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown redact pattern %q", name)
+		}
+		pattern.name = name
+		r.patterns = append(r.patterns, pattern)
+	}
+
+	for _, text := range cfg.RedactRegexes {
+		if text == "" {
+			continue
+		}
+
+		re, err := regexp.Compile(text)
+		if err != nil {
+			return nil, fmt.Errorf("redact regex %q: %w", text, err)
+		}
+		r.patterns = append(r.patterns, redactPattern{name: text, re: re})
+	}
+
+	return r, nil
+}
+
+// Process redacts the entries' records, scopes and resources in place.
+//
+// Scopes and resources shared by consecutive entries, as produced by Entries,
+// are only redacted once.
+func (r *Redactor) Process(ctx context.Context, entries []Entry) []Entry {
+	if len(r.patterns) == 0 {
+		return entries
+	}
+
+	counts := make([]int64, len(r.patterns))
+	for i, e := range entries {
+		if i == 0 || e.Resource != entries[i-1].Resource {
+			r.redactAttributes(e.Resource.GetAttributes(), counts)
+		}
+		if i == 0 || e.Scope != entries[i-1].Scope {
+			r.redactAttributes(e.Scope.GetAttributes(), counts)
+		}
+		r.redactValue(e.Record.GetBody(), counts)
+		r.redactAttributes(e.Record.GetAttributes(), counts)
+	}
+
+	for i, n := range counts {
+		if n > 0 {
+			metrics.Redactions.Add(ctx, n, metric.WithAttributes(attribute.String("pattern", r.patterns[i].name)))
+		}
+	}
+	return entries
+}
+
+// redact returns s with its sensitive values redacted, counting them by pattern index.
+func (r *Redactor) redact(s string, counts []int64) string {
+	var matches []redactMatch
+	for i, p := range r.patterns {
+		for _, loc := range p.re.FindAllStringIndex(s, -1) {
+			if loc[0] == loc[1] || (p.valid != nil && !p.valid(s, loc[0], loc[1])) {
+				continue
+			}
+			matches = append(matches, redactMatch{start: loc[0], end: loc[1], pattern: i})
+		}
+	}
+	if len(matches) == 0 {
+		return s
+	}
+
+	// Matches are replaced in a single pass, so the replacements themselves are
+	// never matched, and the longest of overlapping matches wins.
+	slices.SortFunc(matches, func(a, b redactMatch) int {
+		if a.start != b.start {
+			return a.start - b.start
+		}
+		return b.end - a.end
+	})
+
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		if m.start < last {
+			continue
+		}
+		b.WriteString(s[last:m.start])
+		b.WriteString(r.replacement(s[m.start:m.end]))
+		last = m.end
+		counts[m.pattern]++
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+func (r *Redactor) replacement(value string) string {
+	if !r.hash {
+		return redactedMask
+	}
+
+	mac := hmac.New(sha256.New, []byte(r.salt))
+	mac.Write([]byte(value))
+	return hashPrefix + hex.EncodeToString(mac.Sum(nil))[:hashLength]
+}
+
+func (r *Redactor) redactAttributes(attributes []*commonpb.KeyValue, counts []int64) {
+	for _, kv := range attributes {
+		r.redactValue(kv.GetValue(), counts)
+	}
+}
+
+// redactValue redacts string values in place, recursing into arrays and maps.
+func (r *Redactor) redactValue(v *commonpb.AnyValue, counts []int64) {
+	switch value := v.GetValue().(type) {
+	case *commonpb.AnyValue_StringValue:
+		value.StringValue = r.redact(value.StringValue, counts)
+	case *commonpb.AnyValue_ArrayValue:
+		for _, item := range value.ArrayValue.GetValues() {
+			r.redactValue(item, counts)
+		}
+	case *commonpb.AnyValue_KvlistValue:
+		r.redactAttributes(value.KvlistValue.GetValues(), counts)
+	}
+}
+
+// luhn reports whether the digits of a card number, ignoring separators, pass the Luhn check.
+func luhn(number string) bool {
+	sum, double := 0, false
+	for i := len(number) - 1; i >= 0; i-- {
+		c := number[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+
+		d := int(c - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// isAddressRune reports whether a rune can be part of an IPv6 address or a word.
+func isAddressRune(r rune) bool {
+	return r == ':' || r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,105 @@
+package processor_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+
+	"github.com/miguelhrocha/otel-collector/config"
+	"github.com/miguelhrocha/otel-collector/metrics"
+	"github.com/miguelhrocha/otel-collector/processor"
+)
+
+func TestRedactor(t *testing.T) {
+	require.NoError(t, metrics.InitMetrics(otel.Meter("test")))
+
+	redact := func(t *testing.T, cfg config.Config, body string) string {
+		t.Helper()
+
+		redactor, err := processor.NewRedactor(cfg)
+		require.NoError(t, err)
+
+		entries := redactor.Process(context.Background(), []processor.Entry{{Record: &logspb.LogRecord{Body: stringValue(body)}}})
+		return entries[0].Record.GetBody().GetStringValue()
+	}
+
+	builtIn := config.Config{RedactPatterns: []string{"email", "credit_card", "ip"}}
+
+	for _, tc := range []struct {
+		name, body, want string
+	}{
+		{name: "email", body: "signup from jane.doe+test@example.co.uk ok", want: "signup from [REDACTED] ok"},
+		{name: "credit card", body: "card 4111 1111 1111 1111 declined", want: "card [REDACTED] declined"},
+		{name: "dashed credit card", body: "card 5500-0000-0000-0004", want: "card [REDACTED]"},
+		{name: "number failing the Luhn check", body: "order 4111111111111112", want: "order 4111111111111112"},
+		{name: "IPv4", body: "client 192.168.0.12 connected.", want: "client [REDACTED] connected."},
+		{name: "IPv6", body: "client [2001:db8::8a2e:370:7334]:443 and ::1", want: "client [[REDACTED]]:443 and [REDACTED]"},
+		{name: "times and scoped names", body: "at 12:30:45 in Foo::bar", want: "at 12:30:45 in Foo::bar"},
+		{name: "nothing sensitive", body: "payment declined", want: "payment declined"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, redact(t, builtIn, tc.body))
+		})
+	}
+
+	t.Run("custom regexes", func(t *testing.T) {
+		cfg := config.Config{RedactPatterns: []string{"email"}, RedactRegexes: []string{`user_id=\d+`}}
+		assert.Equal(t, "[REDACTED] by [REDACTED]", redact(t, cfg, "user_id=42 by bob@example.com"))
+	})
+
+	t.Run("hashes values so they remain groupable", func(t *testing.T) {
+		cfg := config.Config{RedactPatterns: []string{"email"}, RedactMode: processor.RedactModeHash, RedactHashSalt: "pepper"}
+
+		alice := redact(t, cfg, "login alice@example.com")
+		assert.Equal(t, "login sha256:e58e539ebd6f4e2a", alice, "Expected the truncated HMAC-SHA256 of the value keyed with the salt")
+		assert.Equal(t, alice, redact(t, cfg, "login alice@example.com"))
+		assert.NotEqual(t, alice, redact(t, cfg, "login bob@example.com"))
+
+		cfg.RedactHashSalt = "salt"
+		assert.NotEqual(t, alice, redact(t, cfg, "login alice@example.com"))
+	})
+
+	t.Run("redacts attributes, scopes and resources", func(t *testing.T) {
+		redactor, err := processor.NewRedactor(builtIn)
+		require.NoError(t, err)
+
+		resource := &resourcepb.Resource{Attributes: []*commonpb.KeyValue{{Key: "host.ip", Value: stringValue("10.0.0.1")}}}
+		scope := &commonpb.InstrumentationScope{Attributes: []*commonpb.KeyValue{{Key: "owner", Value: stringValue("ops@example.com")}}}
+		logRecord := &logspb.LogRecord{
+			Attributes: []*commonpb.KeyValue{
+				{Key: "user", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_KvlistValue{KvlistValue: &commonpb.KeyValueList{
+					Values: []*commonpb.KeyValue{{Key: "email", Value: stringValue("jane@example.com")}},
+				}}}},
+				{Key: "status", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: 4111111111111111}}},
+			},
+		}
+
+		redactor.Process(context.Background(), []processor.Entry{
+			{Resource: resource, Scope: scope, Record: logRecord},
+			{Resource: resource, Scope: scope, Record: &logspb.LogRecord{}},
+		})
+
+		assert.Equal(t, "[REDACTED]", resource.GetAttributes()[0].GetValue().GetStringValue())
+		assert.Equal(t, "[REDACTED]", scope.GetAttributes()[0].GetValue().GetStringValue())
+		assert.Equal(t, "[REDACTED]", logRecord.GetAttributes()[0].GetValue().GetKvlistValue().GetValues()[0].GetValue().GetStringValue())
+		assert.Equal(t, int64(4111111111111111), logRecord.GetAttributes()[1].GetValue().GetIntValue())
+	})
+
+	t.Run("rejects invalid configs", func(t *testing.T) {
+		for _, cfg := range []config.Config{
+			{RedactPatterns: []string{"phone"}},
+			{RedactRegexes: []string{"("}},
+			{RedactMode: "encrypt"},
+			{RedactMode: processor.RedactModeHash},
+		} {
+			_, err := processor.NewRedactor(cfg)
+			assert.Error(t, err)
+		}
+	})
+}
@@ -0,0 +1,111 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand/v2"
+
+	"github.com/segmentio/fasthash/fnv1a"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+
+	"github.com/miguelhrocha/otel-collector/config"
+	"github.com/miguelhrocha/otel-collector/internal/hashutil"
+	"github.com/miguelhrocha/otel-collector/metrics"
+	"github.com/miguelhrocha/otel-collector/otel"
+)
+
+// Sampler keeps a fraction of log records.
+//
+// Records with a trace ID are sampled deterministically by a hash of it, so all
+// the records of a sampled trace are kept, across requests and collector instances.
+// Records without a trace ID are sampled at random.
+//
+// Use NewSampler to create a new Sampler.
+type Sampler struct {
+	ratio        float64
+	attributeKey string
+	rates        map[string]float64
+}
+
+// NewSampler creates a Sampler with the ratios of the config's SamplingRatio and SamplingRates fields.
+//
+// It returns an error if a ratio is not between 0 and 1.
+func NewSampler(cfg config.Config) (*Sampler, error) {
+	if err := checkRatio(cfg.SamplingRatio); err != nil {
+		return nil, err
+	}
+	for value, ratio := range cfg.SamplingRates {
+		if err := checkRatio(ratio); err != nil {
+			return nil, fmt.Errorf("%q: %w", value, err)
+		}
+	}
+
+	return &Sampler{
+		ratio:        cfg.SamplingRatio,
+		attributeKey: cfg.SamplingAttributeKey,
+		rates:        cfg.SamplingRates,
+	}, nil
+}
+
+// Sample reports whether a log record is kept, and the ratio it was sampled at.
+func (s *Sampler) Sample(
+	logRecord *logspb.LogRecord,
+	scope *commonpb.InstrumentationScope,
+	resource *resourcepb.Resource,
+) (bool, float64) {
+	ratio := s.ratio
+	if s.attributeKey != "" {
+		if value, ok := otel.ExtractString(s.attributeKey, logRecord, scope, resource); ok {
+			if r, ok := s.rates[value]; ok {
+				ratio = r
+			}
+		}
+	}
+
+	switch {
+	case ratio >= 1:
+		return true, 1
+	case ratio <= 0:
+		return false, 0
+	}
+
+	return sampleValue(logRecord.GetTraceId()) < ratio, ratio
+}
+
+// Process drops the entries not sampled, counting them in metrics.SampledOut,
+// and scales the weight of the others by the inverse of their sampling ratio.
+func (s *Sampler) Process(ctx context.Context, entries []Entry) []Entry {
+	kept := entries[:0]
+	for _, e := range entries {
+		sampled, ratio := s.Sample(e.Record, e.Scope, e.Resource)
+		if !sampled {
+			metrics.SampledOut.Add(ctx, 1)
+			continue
+		}
+
+		e.Weight /= ratio
+		kept = append(kept, e)
+	}
+	return kept
+}
+
+// sampleValue returns a value uniformly distributed in [0, 1) deciding whether
+// a record is sampled, derived from its trace ID if it has one.
+func sampleValue(traceID []byte) float64 {
+	if len(traceID) == 0 {
+		return rand.Float64()
+	}
+
+	// Keep the 53 bits a float64 can represent exactly.
+	return math.Ldexp(float64(hashutil.Mix64(fnv1a.HashBytes64(traceID))>>11), -53)
+}
+
+func checkRatio(ratio float64) error {
+	if math.IsNaN(ratio) || ratio < 0 || ratio > 1 {
+		return fmt.Errorf("sampling ratio %g is not between 0 and 1", ratio)
+	}
+	return nil
+}
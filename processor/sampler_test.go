@@ -0,0 +1,89 @@
+package processor_test
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+
+	"github.com/miguelhrocha/otel-collector/config"
+	"github.com/miguelhrocha/otel-collector/processor"
+)
+
+func TestSampler(t *testing.T) {
+	t.Run("samples by ratio", func(t *testing.T) {
+		sampler, err := processor.NewSampler(config.Config{SamplingRatio: 0.25})
+		require.NoError(t, err)
+
+		kept := 0
+		for i := range 10_000 {
+			ok, ratio := sampler.Sample(&logspb.LogRecord{TraceId: traceID(i)}, nil, nil)
+			assert.Equal(t, 0.25, ratio)
+			if ok {
+				kept++
+			}
+		}
+		assert.InDelta(t, 2500, kept, 150)
+	})
+
+	t.Run("keeps all or none of the records of a trace", func(t *testing.T) {
+		sampler, err := processor.NewSampler(config.Config{SamplingRatio: 0.5})
+		require.NoError(t, err)
+
+		for i := range 100 {
+			first, _ := sampler.Sample(&logspb.LogRecord{TraceId: traceID(i), Body: stringValue("first")}, nil, nil)
+			for range 5 {
+				ok, _ := sampler.Sample(&logspb.LogRecord{TraceId: traceID(i), Body: stringValue("other")}, nil, nil)
+				assert.Equal(t, first, ok, "trace %d", i)
+			}
+		}
+	})
+
+	t.Run("uses the rate of the attribute value", func(t *testing.T) {
+		sampler, err := processor.NewSampler(config.Config{
+			SamplingRatio:        1,
+			SamplingAttributeKey: "service.name",
+			SamplingRates:        map[string]float64{"chatty": 0},
+		})
+		require.NoError(t, err)
+
+		resource := func(service string) *resourcepb.Resource {
+			return &resourcepb.Resource{Attributes: []*commonpb.KeyValue{{Key: "service.name", Value: stringValue(service)}}}
+		}
+
+		ok, _ := sampler.Sample(&logspb.LogRecord{}, nil, resource("chatty"))
+		assert.False(t, ok)
+
+		ok, ratio := sampler.Sample(&logspb.LogRecord{}, nil, resource("quiet"))
+		assert.True(t, ok)
+		assert.Equal(t, 1.0, ratio)
+	})
+
+	t.Run("drops every record at a ratio of 0", func(t *testing.T) {
+		sampler, err := processor.NewSampler(config.Config{SamplingRatio: 0})
+		require.NoError(t, err)
+
+		for i := range 100 {
+			ok, _ := sampler.Sample(&logspb.LogRecord{TraceId: traceID(i)}, nil, nil)
+			assert.False(t, ok)
+		}
+	})
+
+	t.Run("rejects ratios out of range", func(t *testing.T) {
+		_, err := processor.NewSampler(config.Config{SamplingRatio: 1.5})
+		assert.Error(t, err)
+
+		_, err = processor.NewSampler(config.Config{SamplingRates: map[string]float64{"chatty": -0.1}})
+		assert.Error(t, err)
+	})
+}
+
+func traceID(i int) []byte {
+	id := make([]byte, 16)
+	binary.BigEndian.PutUint64(id[8:], uint64(i))
+	return id
+}
@@ -0,0 +1,222 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/miguelhrocha/otel-collector/config"
+	"github.com/miguelhrocha/otel-collector/metrics"
+)
+
+const (
+	authorizationHeader = "authorization"
+	apiKeyHeader        = "x-api-key"
+	bearerPrefix        = "bearer "
+
+	// healthServicePrefix is the prefix of the gRPC health check methods,
+	// left unauthenticated for load balancers and orchestrators.
+	healthServicePrefix = "/grpc.health.v1.Health/"
+)
+
+// principalKey is the context key of the authenticated principal.
+type principalKey struct{}
+
+// PrincipalFromContext returns the principal a request was authenticated as, see config.AuthTokens.
+func PrincipalFromContext(ctx context.Context) (string, bool) {
+	principal, ok := ctx.Value(principalKey{}).(string)
+	return principal, ok
+}
+
+// Authenticator authenticates export requests by bearer token or API key.
+//
+// Tokens are only kept as SHA-256 hashes, so the keys file never holds them in
+// clear, and looking them up does not leak their content through timing.
+//
+// Use NewAuthenticator to create a new Authenticator.
+type Authenticator struct {
+	// principals holds the principal of each token, by token hash.
+	principals map[[sha256.Size]byte]string
+}
+
+// NewAuthenticator creates an Authenticator accepting the tokens of the config's
+// AuthTokens and AuthKeysFile fields.
+//
+// It returns nil if neither is set, and an error if the keys file cannot be read or parsed,
+// or if the same token is configured more than once.
+func NewAuthenticator(cfg config.Config) (*Authenticator, error) {
+	if len(cfg.AuthTokens) == 0 && cfg.AuthKeysFile == "" {
+		return nil, nil
+	}
+
+	a := &Authenticator{principals: make(map[[sha256.Size]byte]string, len(cfg.AuthTokens))}
+	for principal, token := range cfg.AuthTokens {
+		if token == "" {
+			return nil, fmt.Errorf("empty auth token for principal %q", principal)
+		}
+		if err := a.addKey(sha256.Sum256([]byte(token)), principal); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.AuthKeysFile != "" {
+		if err := a.loadKeys(cfg.AuthKeysFile); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// loadKeys reads a file of hashed keys, see config.AuthKeysFile.
+func (a *Authenticator) loadKeys(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("opening auth keys file: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return fmt.Errorf("auth keys file %s:%d: expected a principal and a key hash", path, line)
+		}
+
+		var hash [sha256.Size]byte
+		if n, err := hex.Decode(hash[:], []byte(fields[1])); err != nil || n != sha256.Size {
+			return fmt.Errorf("auth keys file %s:%d: invalid SHA-256 hash", path, line)
+		}
+		if err := a.addKey(hash, fields[0]); err != nil {
+			return fmt.Errorf("auth keys file %s:%d: %w", path, line, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// addKey registers the principal of a token hash, which must not authenticate another principal already.
+func (a *Authenticator) addKey(hash [sha256.Size]byte, principal string) error {
+	if other, ok := a.principals[hash]; ok {
+		return fmt.Errorf("principals %q and %q share the same auth token", other, principal)
+	}
+	a.principals[hash] = principal
+	return nil
+}
+
+// authenticate returns the principal of a token.
+func (a *Authenticator) authenticate(token string) (string, bool) {
+	if token == "" {
+		return "", false
+	}
+
+	principal, ok := a.principals[sha256.Sum256([]byte(token))]
+	return principal, ok
+}
+
+// UnaryServerInterceptor returns a gRPC interceptor rejecting the calls without a
+// known token with codes.Unauthenticated, and attaching the principal of the others
+// to their context. Health checks are not authenticated.
+func (a *Authenticator) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := a.authenticateCall(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns the streaming counterpart of UnaryServerInterceptor.
+func (a *Authenticator) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := a.authenticateCall(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// authenticateCall returns the context of a gRPC call with its principal attached,
+// or an Unauthenticated error if the call has no known token.
+func (a *Authenticator) authenticateCall(ctx context.Context, method string) (context.Context, error) {
+	if strings.HasPrefix(method, healthServicePrefix) {
+		return ctx, nil
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	principal, ok := a.authenticate(tokenFromHeaders(md.Get(authorizationHeader), md.Get(apiKeyHeader)))
+	if !ok {
+		reject(ctx, "grpc")
+		return nil, status.Error(codes.Unauthenticated, "missing or invalid token")
+	}
+	return context.WithValue(ctx, principalKey{}, principal), nil
+}
+
+// authenticatedStream is a grpc.ServerStream whose context holds the principal.
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+// HTTPHandler wraps an OTLP/HTTP handler, rejecting the requests without a known token
+// with 401 Unauthorized, and attaching the principal of the others to their context.
+func (a *Authenticator) HTTPHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		principal, ok := a.authenticate(tokenFromHeaders(r.Header.Values(authorizationHeader), r.Header.Values(apiKeyHeader)))
+		if !ok {
+			reject(ctx, "http")
+
+			contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			if contentType != contentTypeProtobuf {
+				contentType = contentTypeJSON
+			}
+			writeStatus(w, contentType, http.StatusUnauthorized, status.New(codes.Unauthenticated, "missing or invalid token"))
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(ctx, principalKey{}, principal)))
+	})
+}
+
+// tokenFromHeaders returns the bearer token of the authorization header, or else the API key.
+func tokenFromHeaders(authorization, apiKey []string) string {
+	if len(authorization) > 0 {
+		if len(authorization[0]) > len(bearerPrefix) && strings.EqualFold(authorization[0][:len(bearerPrefix)], bearerPrefix) {
+			return strings.TrimSpace(authorization[0][len(bearerPrefix):])
+		}
+	}
+	if len(apiKey) > 0 {
+		return apiKey[0]
+	}
+	return ""
+}
+
+func reject(ctx context.Context, transport string) {
+	slog.DebugContext(ctx, "Rejected unauthenticated request", slog.String("transport", transport))
+	metrics.AuthRejected.Add(ctx, 1, metric.WithAttributes(attribute.String("transport", transport)))
+}
@@ -0,0 +1,151 @@
+package service_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/miguelhrocha/otel-collector/config"
+	"github.com/miguelhrocha/otel-collector/metrics"
+	"github.com/miguelhrocha/otel-collector/service"
+)
+
+func TestAuthenticator(t *testing.T) {
+	require.NoError(t, metrics.InitMetrics(otel.Meter("test")))
+
+	hash := sha256.Sum256([]byte("hashed-key"))
+	keysFile := filepath.Join(t.TempDir(), "keys")
+	require.NoError(t, os.WriteFile(keysFile, []byte("# API keys\n\npayments "+hex.EncodeToString(hash[:])+"\n"), 0o600))
+
+	authenticator, err := service.NewAuthenticator(config.Config{
+		AuthTokens:   map[string]string{"checkout": "s3cr3t"},
+		AuthKeysFile: keysFile,
+	})
+	require.NoError(t, err)
+
+	t.Run("is disabled without tokens", func(t *testing.T) {
+		authenticator, err := service.NewAuthenticator(config.Config{})
+		require.NoError(t, err)
+		assert.Nil(t, authenticator)
+	})
+
+	t.Run("authenticates gRPC calls", func(t *testing.T) {
+		interceptor := authenticator.UnaryServerInterceptor()
+		info := &grpc.UnaryServerInfo{FullMethod: "/opentelemetry.proto.collector.logs.v1.LogsService/Export"}
+		principal := func(ctx context.Context, _ any) (any, error) {
+			p, _ := service.PrincipalFromContext(ctx)
+			return p, nil
+		}
+
+		for _, tc := range []struct {
+			name      string
+			md        metadata.MD
+			principal string
+		}{
+			{name: "bearer token", md: metadata.Pairs("authorization", "Bearer s3cr3t"), principal: "checkout"},
+			{name: "API key", md: metadata.Pairs("x-api-key", "s3cr3t"), principal: "checkout"},
+			{name: "hashed key", md: metadata.Pairs("authorization", "bearer hashed-key"), principal: "payments"},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				resp, err := interceptor(metadata.NewIncomingContext(context.Background(), tc.md), nil, info, principal)
+				require.NoError(t, err)
+				assert.Equal(t, tc.principal, resp)
+			})
+		}
+
+		for _, md := range []metadata.MD{
+			nil,
+			metadata.Pairs("authorization", "Bearer wrong"),
+			metadata.Pairs("authorization", "Basic s3cr3t"),
+		} {
+			_, err := interceptor(metadata.NewIncomingContext(context.Background(), md), nil, info, principal)
+			assert.Equal(t, codes.Unauthenticated, status.Code(err), md)
+		}
+
+		health := &grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Check"}
+		_, err := interceptor(context.Background(), nil, health, principal)
+		assert.NoError(t, err, "health checks are not authenticated")
+	})
+
+	t.Run("authenticates gRPC streams", func(t *testing.T) {
+		interceptor := authenticator.StreamServerInterceptor()
+		info := &grpc.StreamServerInfo{FullMethod: "/example.Service/Stream"}
+
+		var principal string
+		handler := func(_ any, ss grpc.ServerStream) error {
+			principal, _ = service.PrincipalFromContext(ss.Context())
+			return nil
+		}
+
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-api-key", "s3cr3t"))
+		require.NoError(t, interceptor(nil, &fakeServerStream{ctx: ctx}, info, handler))
+		assert.Equal(t, "checkout", principal)
+
+		err := interceptor(nil, &fakeServerStream{ctx: context.Background()}, info, handler)
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+		health := &grpc.StreamServerInfo{FullMethod: "/grpc.health.v1.Health/Watch"}
+		assert.NoError(t, interceptor(nil, &fakeServerStream{ctx: context.Background()}, health, handler))
+	})
+
+	t.Run("authenticates HTTP requests", func(t *testing.T) {
+		var principal string
+		handler := authenticator.HTTPHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, _ = service.PrincipalFromContext(r.Context())
+		}))
+
+		req := httptest.NewRequest(http.MethodPost, service.LogsPath, nil)
+		req.Header.Set("X-Api-Key", "hashed-key")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "payments", principal)
+
+		req = httptest.NewRequest(http.MethodPost, service.LogsPath, nil)
+		req.Header.Set("Content-Type", "application/x-protobuf")
+		req.Header.Set("Authorization", "Bearer wrong")
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Equal(t, "application/x-protobuf", rec.Header().Get("Content-Type"))
+	})
+
+	t.Run("rejects invalid configs", func(t *testing.T) {
+		invalidFile := filepath.Join(t.TempDir(), "keys")
+		require.NoError(t, os.WriteFile(invalidFile, []byte("payments not-a-hash\n"), 0o600))
+
+		for _, cfg := range []config.Config{
+			{AuthTokens: map[string]string{"checkout": ""}},
+			{AuthKeysFile: filepath.Join(t.TempDir(), "missing")},
+			{AuthKeysFile: invalidFile},
+			{AuthTokens: map[string]string{"checkout": "shared", "payments": "shared"}},
+			{AuthTokens: map[string]string{"checkout": "hashed-key"}, AuthKeysFile: keysFile},
+		} {
+			_, err := service.NewAuthenticator(cfg)
+			assert.Error(t, err)
+		}
+	})
+}
+
+// fakeServerStream is a grpc.ServerStream only providing a context.
+type fakeServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *fakeServerStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,210 @@
+package service
+
+import (
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/miguelhrocha/otel-collector/config"
+)
+
+const (
+	// LogsPath is the OTLP/HTTP path for exporting logs.
+	LogsPath = "/v1/logs"
+
+	contentTypeProtobuf = "application/x-protobuf"
+	contentTypeJSON     = "application/json"
+)
+
+// LogsHTTPHandler is an OTLP/HTTP receiver for logs.
+//
+// It decodes protobuf and JSON encoded ExportLogsServiceRequest bodies
+// and hands them to the same LogsServiceServer used by the gRPC receiver,
+// so both transports share the aggregation and deduplication path.
+type LogsHTTPHandler struct {
+	logs        collogspb.LogsServiceServer
+	maxBodySize int64
+}
+
+// NewLogsHTTPHandler creates a new http.Handler serving POST /v1/logs.
+func NewLogsHTTPHandler(cfg config.Config, logs collogspb.LogsServiceServer) http.Handler {
+	h := &LogsHTTPHandler{
+		logs:        logs,
+		maxBodySize: int64(cfg.MaxReceiveMessageSize),
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("POST "+LogsPath, h)
+	return mux
+}
+
+// ServeHTTP handles a single OTLP/HTTP export request.
+//
+// The response uses the same encoding as the request, as required by the OTLP/HTTP specification.
+func (h *LogsHTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	contentType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || (contentType != contentTypeProtobuf && contentType != contentTypeJSON) {
+		http.Error(w, fmt.Sprintf("unsupported content type %q", r.Header.Get("Content-Type")), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	body, err := h.readBody(r)
+	if err != nil {
+		writeStatus(w, contentType, http.StatusBadRequest, status.New(codes.InvalidArgument, err.Error()))
+		return
+	}
+
+	request := &collogspb.ExportLogsServiceRequest{}
+	if err := unmarshalRequest(contentType, body, request); err != nil {
+		writeStatus(w, contentType, http.StatusBadRequest, status.Newf(codes.InvalidArgument, "decoding request: %v", err))
+		return
+	}
+
+	response, err := h.logs.Export(ctx, request)
+	if err != nil {
+		st := status.Convert(err)
+		writeStatus(w, contentType, httpStatusFromCode(st.Code()), st)
+		return
+	}
+
+	writeMessage(w, contentType, http.StatusOK, response)
+}
+
+// readBody reads the request body, transparently decompressing gzip payloads.
+//
+// The body is limited to maxBodySize bytes after decompression.
+func (h *LogsHTTPHandler) readBody(r *http.Request) ([]byte, error) {
+	var reader io.Reader = r.Body
+
+	switch r.Header.Get("Content-Encoding") {
+	case "", "identity":
+	case "gzip":
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip body: %w", err)
+		}
+		defer gz.Close()
+		reader = gz
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", r.Header.Get("Content-Encoding"))
+	}
+
+	if h.maxBodySize > 0 {
+		// Read one extra byte to detect bodies over the limit.
+		reader = io.LimitReader(reader, h.maxBodySize+1)
+	}
+
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if h.maxBodySize > 0 && int64(len(body)) > h.maxBodySize {
+		return nil, errors.New("request body too large")
+	}
+
+	return body, nil
+}
+
+func unmarshalRequest(contentType string, body []byte, request *collogspb.ExportLogsServiceRequest) error {
+	if contentType == contentTypeProtobuf {
+		return proto.Unmarshal(body, request)
+	}
+
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(body, request); err != nil {
+		return err
+	}
+
+	fixJSONIDs(request)
+	return nil
+}
+
+// fixJSONIDs converts trace and span IDs decoded from OTLP/JSON back into raw bytes.
+//
+// OTLP/JSON encodes trace and span IDs as hex strings, while protojson decodes
+// bytes fields as base64. A 32 character hex trace ID decodes as 24 base64 bytes
+// (and a 16 character span ID as 12), so re-encoding those recovers the hex string.
+func fixJSONIDs(request *collogspb.ExportLogsServiceRequest) {
+	for _, resourceLog := range request.GetResourceLogs() {
+		for _, scopeLog := range resourceLog.GetScopeLogs() {
+			for _, logRecord := range scopeLog.GetLogRecords() {
+				logRecord.TraceId = hexID(logRecord.GetTraceId(), 16)
+				logRecord.SpanId = hexID(logRecord.GetSpanId(), 8)
+			}
+		}
+	}
+}
+
+func hexID(b []byte, size int) []byte {
+	if len(b) != size*2*3/4 {
+		return b
+	}
+
+	decoded, err := hex.DecodeString(base64.StdEncoding.EncodeToString(b))
+	if err != nil {
+		return b
+	}
+	return decoded
+}
+
+func writeStatus(w http.ResponseWriter, contentType string, code int, st *status.Status) {
+	writeMessage(w, contentType, code, st.Proto())
+}
+
+func writeMessage(w http.ResponseWriter, contentType string, code int, msg proto.Message) {
+	var (
+		body []byte
+		err  error
+	)
+
+	if contentType == contentTypeProtobuf {
+		body, err = proto.Marshal(msg)
+	} else {
+		body, err = protojson.Marshal(msg)
+	}
+
+	if err != nil {
+		slog.Error("Failed to encode OTLP/HTTP response", slog.Any("error", err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(code)
+	if _, err := w.Write(body); err != nil {
+		slog.Debug("Failed to write OTLP/HTTP response", slog.Any("error", err))
+	}
+}
+
+// httpStatusFromCode maps gRPC status codes returned by Export to HTTP status codes
+// following the OTLP/HTTP specification.
+func httpStatusFromCode(code codes.Code) int {
+	switch code {
+	case codes.InvalidArgument:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,110 @@
+package service_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/miguelhrocha/otel-collector/config"
+	"github.com/miguelhrocha/otel-collector/service"
+)
+
+type recordingLogsServer struct {
+	requests []*collogspb.ExportLogsServiceRequest
+
+	collogspb.UnimplementedLogsServiceServer
+}
+
+func (s *recordingLogsServer) Export(_ context.Context, r *collogspb.ExportLogsServiceRequest) (*collogspb.ExportLogsServiceResponse, error) {
+	s.requests = append(s.requests, r)
+	return &collogspb.ExportLogsServiceResponse{}, nil
+}
+
+func TestLogsHTTPHandler(t *testing.T) {
+	cfg := config.Config{MaxReceiveMessageSize: 1024}
+
+	t.Run("accepts protobuf bodies", func(t *testing.T) {
+		logs := &recordingLogsServer{}
+		handler := service.NewLogsHTTPHandler(cfg, logs)
+
+		body, err := proto.Marshal(&collogspb.ExportLogsServiceRequest{
+			ResourceLogs: []*logspb.ResourceLogs{{
+				ScopeLogs: []*logspb.ScopeLogs{{
+					LogRecords: []*logspb.LogRecord{{
+						Body: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: "hello"}},
+					}},
+				}},
+			}},
+		})
+		require.NoError(t, err)
+
+		req := httptest.NewRequest(http.MethodPost, service.LogsPath, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-protobuf")
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/x-protobuf", rec.Header().Get("Content-Type"))
+		require.Len(t, logs.requests, 1)
+		assert.Equal(t, "hello", logs.requests[0].GetResourceLogs()[0].GetScopeLogs()[0].GetLogRecords()[0].GetBody().GetStringValue())
+	})
+
+	t.Run("accepts JSON bodies with hex encoded IDs", func(t *testing.T) {
+		logs := &recordingLogsServer{}
+		handler := service.NewLogsHTTPHandler(cfg, logs)
+
+		body := `{"resourceLogs":[{"scopeLogs":[{"logRecords":[{
+			"traceId":"5b8efff798038103d269b633813fc60c",
+			"spanId":"eee19b7ec3c1b174",
+			"body":{"stringValue":"hello"}
+		}]}]}]}`
+
+		req := httptest.NewRequest(http.MethodPost, service.LogsPath, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		require.Len(t, logs.requests, 1)
+
+		logRecord := logs.requests[0].GetResourceLogs()[0].GetScopeLogs()[0].GetLogRecords()[0]
+		assert.Equal(t, []byte{0x5b, 0x8e, 0xff, 0xf7, 0x98, 0x03, 0x81, 0x03, 0xd2, 0x69, 0xb6, 0x33, 0x81, 0x3f, 0xc6, 0x0c}, logRecord.GetTraceId())
+		assert.Equal(t, []byte{0xee, 0xe1, 0x9b, 0x7e, 0xc3, 0xc1, 0xb1, 0x74}, logRecord.GetSpanId())
+	})
+
+	t.Run("rejects unsupported content types", func(t *testing.T) {
+		handler := service.NewLogsHTTPHandler(cfg, &recordingLogsServer{})
+
+		req := httptest.NewRequest(http.MethodPost, service.LogsPath, strings.NewReader("hello"))
+		req.Header.Set("Content-Type", "text/plain")
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+	})
+
+	t.Run("rejects bodies over the size limit", func(t *testing.T) {
+		handler := service.NewLogsHTTPHandler(cfg, &recordingLogsServer{})
+
+		req := httptest.NewRequest(http.MethodPost, service.LogsPath, bytes.NewReader(make([]byte, 2048)))
+		req.Header.Set("Content-Type", "application/x-protobuf")
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
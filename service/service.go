@@ -6,33 +6,66 @@ import (
 
 	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
 	common "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
 
 	"github.com/miguelhrocha/otel-collector/config"
 	"github.com/miguelhrocha/otel-collector/ingestor"
 	"github.com/miguelhrocha/otel-collector/metrics"
 	"github.com/miguelhrocha/otel-collector/otel"
+	"github.com/miguelhrocha/otel-collector/processor"
 )
 
 type LogsServiceServer struct {
 	addr               string
-	attributeExtractor otel.AttributeExtractor
+	attributeExtractor *otel.AttributeExtractor
+	pipeline           processor.Pipeline
+	scaleCounts        bool
+	identityAttributes []ingestor.IdentityAttribute
+	valueKey           string
+	distributionKey    string
+	distinctKey        string
 	ingestor           *ingestor.Ingestor
 
 	collogspb.UnimplementedLogsServiceServer
 }
 
-func NewLogService(cfg config.Config, ingestor *ingestor.Ingestor) collogspb.LogsServiceServer {
+// NewLogService creates a new LogsServiceServer enqueueing records into the given Ingestor.
+//
+// It returns an error if the attribute extraction or processor config is invalid.
+func NewLogService(cfg config.Config, in *ingestor.Ingestor) (collogspb.LogsServiceServer, error) {
+	attributeExtractor, err := otel.NewAttributeExtractor(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	pipeline, err := processor.NewPipeline(cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	s := &LogsServiceServer{
 		addr:               cfg.Addr,
-		attributeExtractor: *otel.NewAttributeExtractor(cfg.AttributeKey),
-		ingestor:           ingestor,
+		attributeExtractor: attributeExtractor,
+		pipeline:           pipeline,
+		scaleCounts:        cfg.SamplingScaleCounts,
+		valueKey:           cfg.ValueAttributeKey,
+		distributionKey:    cfg.DistributionAttributeKey,
+		distinctKey:        cfg.DistinctAttributeKey,
+		ingestor:           in,
+	}
+
+	// Attributes are only needed to identify duplicates.
+	if cfg.DedupBackend != ingestor.DedupBackendNone {
+		s.identityAttributes = ingestor.IdentityAttributes(cfg.DedupFields)
 	}
-	return s
+	return s, nil
 }
 
 // Export handles incoming ExportLogsServiceRequest requests.
 //
-// It extracts the specified attribute from each log record and enqueues it for processing.
+// It runs the log records through the processor pipeline, extracts the
+// specified attributes from the records it keeps and enqueues them for processing.
 func (l *LogsServiceServer) Export(ctx context.Context, request *collogspb.ExportLogsServiceRequest) (*collogspb.ExportLogsServiceResponse, error) {
 	slog.DebugContext(ctx, "Received ExportLogsServiceRequest")
 	metrics.LogsReceivedCounter.Add(ctx, 1)
@@ -42,41 +75,69 @@ func (l *LogsServiceServer) Export(ctx context.Context, request *collogspb.Expor
 		return &collogspb.ExportLogsServiceResponse{}, nil
 	}
 
-	for _, resourceLog := range request.GetResourceLogs() {
-		resource := resourceLog.GetResource()
-		for _, scopeLog := range resourceLog.GetScopeLogs() {
-			scope := scopeLog.GetScope()
-			for _, logRecord := range scopeLog.GetLogRecords() {
-				attributeValue := l.attributeExtractor.Extract(logRecord, scope, resource)
-				if attributeValue == "" {
-					attributeValue = "unknown"
-				}
-
-				r := ingestor.Record{
-					AttrValue: attributeValue,
-					TimeUnix:  logRecord.GetTimeUnixNano(),
-					ObsUnix:   logRecord.GetObservedTimeUnixNano(),
-					Severity:  int32(logRecord.GetSeverityNumber()),
-					Body:      bodyToString(logRecord.GetBody()),
-					TraceID:   string(logRecord.GetTraceId()),
-					SpanID:    string(logRecord.GetSpanId()),
-				}
-
-				if ok := l.ingestor.TryEnqueue(ctx, r); ok {
-					metrics.LogsEnqueuedCounter.Add(ctx, 1)
-				} else {
-					slog.WarnContext(ctx, "Ingestor queue is full, dropping log record",
-						slog.String("attribute_value", attributeValue),
-						slog.Uint64("time_unix", r.TimeUnix),
-					)
-				}
-			}
+	entries := l.pipeline.Process(ctx, processor.Entries(request.GetResourceLogs()))
+	for _, e := range entries {
+		logRecord, scope, resource := e.Record, e.Scope, e.Resource
+		attributeValues := l.attributeExtractor.Extract(logRecord, scope, resource)
+
+		r := ingestor.Record{
+			AttrValues: attributeValues,
+			TimeUnix:   logRecord.GetTimeUnixNano(),
+			ObsUnix:    logRecord.GetObservedTimeUnixNano(),
+			Severity:   int32(logRecord.GetSeverityNumber()),
+			Body:       bodyToString(logRecord.GetBody()),
+			TraceID:    string(logRecord.GetTraceId()),
+			SpanID:     string(logRecord.GetSpanId()),
+			Identity:   l.identity(logRecord, resource),
+		}
+		if l.valueKey != "" {
+			r.Value, r.HasValue = otel.ExtractNumber(l.valueKey, logRecord, scope, resource)
+		}
+		if l.distributionKey != "" {
+			r.Distribution, r.HasDistribution = otel.ExtractNumber(l.distributionKey, logRecord, scope, resource)
+		}
+		if l.distinctKey != "" {
+			r.Distinct, r.HasDistinct = otel.ExtractString(l.distinctKey, logRecord, scope, resource)
+		}
+		if l.scaleCounts {
+			r.Weight = e.Weight
+		}
+
+		if ok := l.ingestor.TryEnqueue(ctx, r); ok {
+			metrics.LogsEnqueuedCounter.Add(ctx, 1)
+		} else {
+			slog.WarnContext(ctx, "Ingestor queue is full, dropping log record",
+				slog.Any("attribute_values", attributeValues),
+				slog.Uint64("time_unix", r.TimeUnix),
+			)
 		}
 	}
 
 	return &collogspb.ExportLogsServiceResponse{}, nil
 }
 
+// identity returns the values of the identity attributes of a log record, see ingestor.Record.Identity.
+//
+// Missing attributes are rendered as empty strings.
+func (l *LogsServiceServer) identity(logRecord *logspb.LogRecord, resource *resourcepb.Resource) []string {
+	if len(l.identityAttributes) == 0 {
+		return nil
+	}
+
+	values := make([]string, len(l.identityAttributes))
+	for i, attr := range l.identityAttributes {
+		attributes := logRecord.GetAttributes()
+		if attr.Resource {
+			attributes = resource.GetAttributes()
+		}
+
+		if v, ok := otel.LookupAttribute(attributes, attr.Key); ok {
+			values[i] = otel.AnyValueAsString(v)
+		}
+	}
+	return values
+}
+
 func bodyToString(v *common.AnyValue) string {
 	if v == nil {
 		return ""
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/miguelhrocha/otel-collector/config"
+)
+
+// tlsVersions are the supported values of config.TLSMinVersion.
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// NewTLSConfig creates the TLS config of the receivers from the config's TLS fields.
+//
+// The certificate, key and client CA files are reloaded when they change on disk,
+// see config.TLSReloadInterval. It returns nil if TLS is disabled, and an error if
+// the TLS fields are inconsistent or the files cannot be loaded.
+func NewTLSConfig(cfg config.Config) (*tls.Config, error) {
+	switch {
+	case cfg.TLSCertFile == "" && cfg.TLSKeyFile == "" && cfg.TLSClientCAFile == "":
+		return nil, nil
+	case cfg.TLSCertFile == "" || cfg.TLSKeyFile == "":
+		return nil, errors.New("TLS requires both a certificate and a key file")
+	}
+
+	minVersion, ok := tlsVersions[cfg.TLSMinVersion]
+	if !ok {
+		return nil, fmt.Errorf("unsupported minimum TLS version %q", cfg.TLSMinVersion)
+	}
+
+	r := &certReloader{
+		files:      []string{cfg.TLSCertFile, cfg.TLSKeyFile},
+		interval:   cfg.TLSReloadInterval,
+		minVersion: minVersion,
+		mTLS:       cfg.TLSClientCAFile != "",
+	}
+	if r.mTLS {
+		r.files = append(r.files, cfg.TLSClientCAFile)
+	}
+
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	r.checked = time.Now()
+
+	return &tls.Config{
+		MinVersion:         minVersion,
+		GetConfigForClient: r.configForClient,
+	}, nil
+}
+
+// certReloader serves the TLS config loaded from the certificate, key and client
+// CA files, reloading it when their modification time or size change.
+type certReloader struct {
+	// files are the certificate, key and, with mutual TLS, client CA files.
+	files      []string
+	interval   time.Duration
+	minVersion uint16
+	mTLS       bool
+
+	config atomic.Pointer[tls.Config]
+
+	// mu guards the fields below, and serializes reloads.
+	mu      sync.Mutex
+	checked time.Time
+	stamps  []fileStamp
+}
+
+// fileStamp identifies a version of a file.
+type fileStamp struct {
+	modTime int64
+	size    int64
+}
+
+func (r *certReloader) configForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	r.maybeReload()
+	return r.config.Load(), nil
+}
+
+// maybeReload reloads the files if they changed, at most once per interval.
+//
+// The current config is kept if they cannot be loaded, e.g. while a rotation
+// has replaced the certificate but not the key yet.
+func (r *certReloader) maybeReload() {
+	if r.interval <= 0 {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.checked) < r.interval {
+		return
+	}
+	r.checked = time.Now()
+
+	changed := false
+	for i, file := range r.files {
+		stamp, err := statFile(file)
+		if err != nil {
+			slog.Warn("Failed to check TLS file for changes", slog.String("file", file), slog.Any("error", err))
+			return
+		}
+		changed = changed || stamp != r.stamps[i]
+	}
+	if !changed {
+		return
+	}
+
+	if err := r.load(); err != nil {
+		slog.Warn("Failed to reload TLS files, keeping the current certificate", slog.Any("error", err))
+		return
+	}
+	slog.Info("Reloaded TLS certificate", slog.String("file", r.files[0]))
+}
+
+// load loads the files into a new config. The caller must hold mu, or own r.
+func (r *certReloader) load() error {
+	stamps := make([]fileStamp, len(r.files))
+	for i, file := range r.files {
+		stamp, err := statFile(file)
+		if err != nil {
+			return err
+		}
+		stamps[i] = stamp
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.files[0], r.files[1])
+	if err != nil {
+		return fmt.Errorf("loading TLS certificate: %w", err)
+	}
+
+	c := &tls.Config{
+		MinVersion:   r.minVersion,
+		Certificates: []tls.Certificate{cert},
+		// The config replaces the one the servers set up, so it must advertise their protocols.
+		NextProtos: []string{"h2", "http/1.1"},
+	}
+
+	if r.mTLS {
+		pem, err := os.ReadFile(r.files[2])
+		if err != nil {
+			return fmt.Errorf("reading TLS client CA file: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in TLS client CA file %s", r.files[2])
+		}
+		c.ClientCAs = pool
+		c.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	r.config.Store(c)
+	r.stamps = stamps
+	return nil
+}
+
+func statFile(file string) (fileStamp, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return fileStamp{}, err
+	}
+	return fileStamp{modTime: info.ModTime().UnixNano(), size: info.Size()}, nil
+}
@@ -0,0 +1,232 @@
+package service_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/miguelhrocha/otel-collector/config"
+	"github.com/miguelhrocha/otel-collector/service"
+)
+
+func TestTLSConfig(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	certFile, keyFile, caFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key"), filepath.Join(dir, "ca.crt")
+	ca.issue(t, "collector", certFile, keyFile)
+	require.NoError(t, os.WriteFile(caFile, ca.certPEM, 0o600))
+
+	clientConfig := func(minVersion, maxVersion uint16) *tls.Config {
+		pool := x509.NewCertPool()
+		pool.AppendCertsFromPEM(ca.certPEM)
+		return &tls.Config{RootCAs: pool, ServerName: "collector", MinVersion: minVersion, MaxVersion: maxVersion}
+	}
+
+	t.Run("is disabled without files", func(t *testing.T) {
+		tlsConfig, err := service.NewTLSConfig(config.Config{})
+		require.NoError(t, err)
+		assert.Nil(t, tlsConfig)
+	})
+
+	t.Run("serves the certificate", func(t *testing.T) {
+		tlsConfig, err := service.NewTLSConfig(config.Config{TLSCertFile: certFile, TLSKeyFile: keyFile, TLSMinVersion: "1.2"})
+		require.NoError(t, err)
+
+		state, clientErr, serverErr := handshake(t, tlsConfig, clientConfig(tls.VersionTLS12, 0))
+		require.NoError(t, clientErr)
+		require.NoError(t, serverErr)
+		assert.Equal(t, "collector", state.PeerCertificates[0].Subject.CommonName)
+	})
+
+	t.Run("enforces the minimum version", func(t *testing.T) {
+		tlsConfig, err := service.NewTLSConfig(config.Config{TLSCertFile: certFile, TLSKeyFile: keyFile, TLSMinVersion: "1.3"})
+		require.NoError(t, err)
+
+		_, clientErr, serverErr := handshake(t, tlsConfig, clientConfig(tls.VersionTLS12, tls.VersionTLS12))
+		assert.Error(t, clientErr)
+		assert.Error(t, serverErr)
+	})
+
+	t.Run("verifies client certificates with mutual TLS", func(t *testing.T) {
+		tlsConfig, err := service.NewTLSConfig(config.Config{
+			TLSCertFile:     certFile,
+			TLSKeyFile:      keyFile,
+			TLSClientCAFile: caFile,
+			TLSMinVersion:   "1.2",
+		})
+		require.NoError(t, err)
+
+		_, _, serverErr := handshake(t, tlsConfig, clientConfig(tls.VersionTLS12, 0))
+		assert.Error(t, serverErr, "client without a certificate")
+
+		clientCertFile, clientKeyFile := filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key")
+		ca.issue(t, "agent", clientCertFile, clientKeyFile)
+		clientCert, err := tls.LoadX509KeyPair(clientCertFile, clientKeyFile)
+		require.NoError(t, err)
+
+		withCert := clientConfig(tls.VersionTLS12, 0)
+		withCert.Certificates = []tls.Certificate{clientCert}
+		_, clientErr, serverErr := handshake(t, tlsConfig, withCert)
+		require.NoError(t, clientErr)
+		require.NoError(t, serverErr)
+
+		selfSigned := newTestCA(t)
+		selfSigned.issue(t, "agent", clientCertFile, clientKeyFile)
+		clientCert, err = tls.LoadX509KeyPair(clientCertFile, clientKeyFile)
+		require.NoError(t, err)
+
+		withCert.Certificates = []tls.Certificate{clientCert}
+		_, _, serverErr = handshake(t, tlsConfig, withCert)
+		assert.Error(t, serverErr, "client certificate of another CA")
+	})
+
+	t.Run("reloads rotated certificates", func(t *testing.T) {
+		dir := t.TempDir()
+		certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+		ca.issue(t, "collector", certFile, keyFile)
+
+		tlsConfig, err := service.NewTLSConfig(config.Config{
+			TLSCertFile:       certFile,
+			TLSKeyFile:        keyFile,
+			TLSMinVersion:     "1.2",
+			TLSReloadInterval: time.Nanosecond,
+		})
+		require.NoError(t, err)
+
+		ca.issue(t, "collector-rotated", certFile, keyFile)
+		future := time.Now().Add(time.Hour)
+		require.NoError(t, os.Chtimes(certFile, future, future))
+
+		client := clientConfig(tls.VersionTLS12, 0)
+		client.ServerName = "collector-rotated"
+		state, clientErr, serverErr := handshake(t, tlsConfig, client)
+		require.NoError(t, clientErr)
+		require.NoError(t, serverErr)
+		assert.Equal(t, "collector-rotated", state.PeerCertificates[0].Subject.CommonName)
+
+		// A half-written rotation keeps the current certificate.
+		require.NoError(t, os.WriteFile(keyFile, []byte("garbage"), 0o600))
+		state, clientErr, _ = handshake(t, tlsConfig, client)
+		require.NoError(t, clientErr)
+		assert.Equal(t, "collector-rotated", state.PeerCertificates[0].Subject.CommonName)
+	})
+
+	t.Run("rejects invalid configs", func(t *testing.T) {
+		for _, cfg := range []config.Config{
+			{TLSCertFile: certFile, TLSMinVersion: "1.2"},
+			{TLSClientCAFile: caFile, TLSMinVersion: "1.2"},
+			{TLSCertFile: certFile, TLSKeyFile: keyFile, TLSMinVersion: "1.1"},
+			{TLSCertFile: certFile, TLSKeyFile: filepath.Join(dir, "missing.key"), TLSMinVersion: "1.2"},
+			{TLSCertFile: certFile, TLSKeyFile: keyFile, TLSClientCAFile: keyFile, TLSMinVersion: "1.2"},
+		} {
+			_, err := service.NewTLSConfig(cfg)
+			assert.Error(t, err)
+		}
+	})
+}
+
+// handshake runs a TLS handshake between a server and a client over a loopback connection.
+func handshake(t *testing.T, serverConfig, clientConfig *tls.Config) (tls.ConnectionState, error, error) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	clientConn, err := net.Dial("tcp", listener.Addr().String())
+	require.NoError(t, err)
+	defer clientConn.Close()
+
+	serverConn, err := listener.Accept()
+	require.NoError(t, err)
+	defer serverConn.Close()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		server := tls.Server(serverConn, serverConfig)
+		err := server.Handshake()
+		if err == nil {
+			// Client certificates are verified after the client's handshake completes in TLS 1.3,
+			// so wait for the client to read the outcome.
+			_, err = server.Write([]byte{1})
+		}
+		serverConn.Close()
+		serverErr <- err
+	}()
+
+	client := tls.Client(clientConn, clientConfig)
+	clientErr := client.Handshake()
+	if clientErr == nil {
+		_, clientErr = client.Read(make([]byte, 1))
+	}
+	clientConn.Close()
+	return client.ConnectionState(), clientErr, <-serverErr
+}
+
+type testCA struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &testCA{cert: cert, key: key, certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue writes a certificate for the name, valid for servers and clients, and its key.
+func (ca *testCA) issue(t *testing.T, name, certFile, keyFile string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+}